@@ -2,6 +2,8 @@ package main
 
 import (
 	"log"
+	"net"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -22,13 +24,36 @@ func main() {
 	// เชื่อมต่อฐานข้อมูล (ถ้า DB ยังไม่ขึ้น โปรแกรมจะ error ทันที — เหมาะสำหรับ early fail)
 	database.Connect(cfg)
 
+	// role ตั้งต้น (admin/teacher) ต้องมีก่อนสร้างบัญชี
+	if err := handlers.EnsureDefaultRoles(); err != nil {
+		log.Printf("[bootstrap] failed to ensure default roles: %v", err)
+	}
+
 	// สร้างบัญชี Admin อัตโนมัติถ้ายังไม่มี
 	if err := handlers.EnsureDefaultAdmin(); err != nil {
 		log.Printf("[bootstrap] failed to ensure default admin: %v", err)
 	}
 
+	// สถานะการเข้าเรียนตั้งต้น + จับคู่ข้อมูลเก่ากับรหัสสถานะ
+	if err := handlers.EnsureAttendanceStatuses(); err != nil {
+		log.Printf("[bootstrap] failed to ensure attendance statuses: %v", err)
+	}
+
+	// ใบลาที่อนุมัติไว้ก่อนหน้า → เขียนแถว "ลา" ที่ยังขาด
+	if err := handlers.BackfillLeaveAttendance(); err != nil {
+		log.Printf("[bootstrap] failed to backfill leave attendance: %v", err)
+	}
+
+	// เติม "ขาด" อัตโนมัติหลังเลิกเรียนทุกวันเรียน
+	handlers.StartAbsenceJob()
+
+	// ส่งต่อใบลาที่ค้างอนุมัติ (ครูประจำชั้นหลัก → รอง → admin)
+	handlers.StartLeaveEscalationJob()
+
 	e := echo.New()
 	e.HideBanner = true
+	// IP ของผู้ใช้ (ตัวนับ login ผิดต่อ IP ฯลฯ) — ไม่เชื่อ header จาก client ตรง ๆ
+	e.IPExtractor = ipExtractor(cfg.TrustedProxies)
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 
@@ -54,3 +79,24 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// ไม่มี proxy → ใช้ IP ที่ต่อเข้ามา; มี proxy → อ่าน X-Forwarded-For เฉพาะช่วงที่เชื่อ
+func ipExtractor(trusted string) echo.IPExtractor {
+	var opts []echo.TrustOption
+	for _, cidr := range strings.Split(trusted, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Fatalf("invalid TRUSTED_PROXIES entry %q: %v", cidr, err)
+		}
+		opts = append(opts, echo.TrustIPRange(ipNet))
+	}
+	if len(opts) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	opts = append(opts, echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false))
+	return echo.ExtractIPFromXFFHeader(opts...)
+}
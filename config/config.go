@@ -9,6 +9,9 @@ type Config struct {
 	AppPort string
 	AppEnv  string
 
+	// CIDR ของ reverse proxy ที่เชื่อ X-Forwarded-For (คั่นด้วย , ; ว่าง = ใช้ IP ที่ต่อเข้ามาตรง ๆ)
+	TrustedProxies string
+
 	DBHost     string
 	DBPort     string
 	DBUser     string
@@ -29,6 +32,8 @@ func Load() *Config {
 		AppPort: get("APP_PORT", "8080"),
 		AppEnv:  get("APP_ENV", "dev"),
 
+		TrustedProxies: get("TRUSTED_PROXIES", ""),
+
 		DBHost:     get("DB_HOST", "localhost"),
 		DBPort:     get("DB_PORT", "5432"),
 		DBUser:     get("DB_USER", "postgres"),
@@ -30,6 +30,24 @@ func Connect(cfg *config.Config) {
 		&models.User{},
 		&models.Parent{},
 		&models.LeaveRequest{},
+		&models.RefreshToken{},       // ✅ refresh token / session
+		&models.ParentStudent{},      // ✅ ผู้ปกครอง ↔ นักเรียน
+		&models.LoginAttempt{},       // ✅ log การ login ผิด
+		&models.LoginThrottle{},      // ✅ ตัวนับ/ล็อกการ login
+		&models.TwoFactorPolicy{},    // ✅ บังคับ 2FA ตาม role
+		&models.PasswordResetToken{}, // ✅ token ลืมรหัสผ่าน
+		&models.Role{},               // ✅ role = ชุดสิทธิ์
+		&models.RolePermission{},
+		&models.AttendanceSubmission{}, // ✅ เช็คชื่อทั้งห้อง (กันส่งซ้ำ)
+		&models.GateDevice{},           // ✅ เครื่องสแกนหน้าประตู
+		&models.StudentCard{},          // ✅ บัตรนักเรียน
+		&models.AttendanceJobRun{},     // ✅ ประวัติงานเติม "ขาด" อัตโนมัติ
+		&models.AttendanceRevision{},   // ✅ ประวัติการแก้ไขการเข้าเรียน
+		&models.AttendanceCorrection{}, // ✅ คำขอแก้ข้อมูลย้อนหลัง (รออนุมัติ)
+		&models.AttendanceStatus{},     // ✅ รายการสถานะการเข้าเรียน
+		&models.LeaveAttachment{},      // ✅ ไฟล์แนบใบลา
+		&models.LeaveRouting{},         // ✅ ประวัติการส่งต่อผู้อนุมัติใบลา
+		&models.LeaveTransition{},      // ✅ ประวัติการเปลี่ยนสถานะใบลา
 	); err != nil {
 		log.Fatalf("auto migrate failed: %v", err)
 	}
@@ -43,4 +61,11 @@ func Connect(cfg *config.Config) {
 			log.Printf("[migrate] dropped legacy column users.password")
 		}
 	}
+
+	// ----- attendances ที่บันทึกก่อนมีคอลัมน์ recorded_at: ใช้ created_at แทน -----
+	if res := DB.Exec(`UPDATE attendances SET recorded_at = created_at WHERE recorded_at IS NULL`); res.Error != nil {
+		log.Printf("[migrate] warn: backfill attendances.recorded_at failed: %v", res.Error)
+	} else if res.RowsAffected > 0 {
+		log.Printf("[migrate] backfilled attendances.recorded_at (%d rows)", res.RowsAffected)
+	}
 }
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/*
+	เติม "ขาด" อัตโนมัติตอนเลิกเรียน
+
+	  - รันเองทุกวันเรียน หลัง TimeOut ของภาคเรียน + ABSENCE_JOB_DELAY_MINUTES
+	    (วันหยุด/เสาร์-อาทิตย์/นอกภาคเรียน ไม่รัน)
+	  - นักเรียนที่ทั้งวันไม่มีแถว เข้า/มาสาย/ออก/ขาด/ลา และไม่มีใบลาที่อนุมัติ → สร้างแถว "ขาด" source = system
+	  - รันซ้ำได้ (คนที่มีแถวแล้วจะไม่ถูกเติมซ้ำ) → admin สั่งรันย้อนหลังรายวันได้
+*/
+
+const (
+	attendanceSourceSystem = "system"
+	absenceJobLockID       = 7301001 // pg advisory lock: กันหลาย instance เติมพร้อมกัน
+)
+
+// สถานะที่ถือว่า "มีข้อมูลของวันนั้นแล้ว" = ทุกหมวดยกเว้น pending (ยังไม่เข้าโรงเรียน ไม่นับ)
+func attendanceRecordedStatuses() []string {
+	var out []string
+	for _, st := range attendanceStatusList() {
+		if st.Category != statusCategoryPending {
+			out = append(out, st.LabelTH)
+		}
+	}
+	return out
+}
+
+// นักเรียนที่ไม่ต้องเช็คชื่อแล้ว
+var inactiveStudentStatuses = []string{"left", "suspended", "graduated", "ลาออก", "พักการเรียน", "จบการศึกษา", "ย้ายออก"}
+
+type AbsenceJobHandler struct {
+	Delay time.Duration // หลัง TimeOut กี่นาทีถึงเติม
+}
+
+func NewAbsenceJobHandler() *AbsenceJobHandler {
+	return &AbsenceJobHandler{Delay: time.Duration(atoiOr(os.Getenv("ABSENCE_JOB_DELAY_MINUTES"), 30)) * time.Minute}
+}
+
+// StartAbsenceJob เริ่ม loop เช็คทุกนาที (เรียกครั้งเดียวตอนเริ่มระบบ)
+func StartAbsenceJob() {
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("ABSENCE_JOB_ENABLED"))); v == "false" || v == "0" {
+		log.Printf("[absence-job] disabled")
+		return
+	}
+	h := NewAbsenceJobHandler()
+	go func() {
+		t := time.NewTicker(time.Minute)
+		defer t.Stop()
+		for now := range t.C {
+			h.tick(now)
+		}
+	}()
+}
+
+func (h *AbsenceJobHandler) tick(now time.Time) {
+	date := now.Format("2006-01-02")
+	info := schoolDayOf(date)
+	if !info.SchoolDay {
+		return
+	}
+	out, ok := minuteOfDay(info.Term.TimeOut)
+	if !ok || now.Hour()*60+now.Minute() < out+int(h.Delay/time.Minute) {
+		return
+	}
+	// รันสำเร็จแล้ววันนี้ → ข้าม (แถวที่ค้าง finished_at ว่าง = process ตายกลางทาง ต้องรันใหม่)
+	var n int64
+	if err := database.DB.Model(&models.AttendanceJobRun{}).
+		Where("date = ? AND kind = ? AND error = '' AND finished_at IS NOT NULL", date, "schedule").
+		Count(&n).Error; err != nil || n > 0 {
+		return
+	}
+	run := fillAbsences(date, "schedule", nil)
+	if run.Error != "" {
+		log.Printf("[absence-job] %s failed: %s", date, run.Error)
+		return
+	}
+	log.Printf("[absence-job] %s marked %d absent", date, run.Marked)
+}
+
+// เติม "ขาด" ของวันที่ date แล้วบันทึกประวัติการรัน
+func fillAbsences(date, kind string, by *uint) models.AttendanceJobRun {
+	run := models.AttendanceJobRun{Date: date, Kind: kind, TriggeredBy: by, StartedAt: time.Now()}
+	_ = database.DB.Create(&run).Error
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", absenceJobLockID).Error; err != nil {
+			return err
+		}
+		var ids []uint
+		if err := tx.Model(&models.Student{}).
+			Where("status NOT IN ?", inactiveStudentStatuses).
+			Where("NOT EXISTS (SELECT 1 FROM attendances a WHERE a.student_id = students.id AND a.date = ? AND a.status IN ? AND a.voided_at IS NULL)",
+				date, attendanceRecordedStatuses()).
+			Where("NOT EXISTS (SELECT 1 FROM leave_requests l WHERE l.student_id = students.id AND l.status = ? AND l.date_from <= ? AND l.date_to >= ?)",
+				"อนุมัติ", date, date).
+			Order("id").
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		now := time.Now()
+		rows := make([]models.Attendance, 0, len(ids))
+		for _, id := range ids {
+			rows = append(rows, models.Attendance{
+				StudentID:  id,
+				Date:       date,
+				Time:       "—",
+				Status:     "ขาด",
+				StatusCode: statusCodeAbsent,
+				Note:       "ระบบบันทึกอัตโนมัติ (ไม่มีการเช็คชื่อ)",
+				RecordedAt: now,
+				Retro:      date != now.Format("2006-01-02"),
+				Source:     attendanceSourceSystem,
+			})
+		}
+		if err := tx.CreateInBatches(&rows, 500).Error; err != nil {
+			return err
+		}
+		run.Marked = len(rows)
+		return nil
+	})
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if run.ID != 0 {
+		_ = database.DB.Model(&run).Updates(map[string]any{
+			"marked": run.Marked, "error": run.Error, "finished_at": run.FinishedAt,
+		}).Error
+	}
+	return run
+}
+
+/* ====================== Handlers ====================== */
+
+// POST /attendance/absences/run
+// body: { date } — รันย้อนหลัง/รันซ้ำสำหรับวันที่ระบุ (ต้องเป็นวันเรียนและเลยเวลาเลิกเรียนแล้ว)
+func (h *AbsenceJobHandler) Run(c echo.Context) error {
+	var req struct {
+		Date string `json:"date"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	req.Date = strings.TrimSpace(req.Date)
+	day, err := time.ParseInLocation("2006-01-02", req.Date, time.Local)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_DATE"})
+	}
+	now := time.Now()
+	if day.After(now) {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "DATE_IN_FUTURE"})
+	}
+	info := schoolDayOf(req.Date)
+	if !info.SchoolDay {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "NOT_SCHOOL_DAY", "reason": info.Reason, "holiday": info.Holiday})
+	}
+	if req.Date == now.Format("2006-01-02") {
+		if out, ok := minuteOfDay(info.Term.TimeOut); ok && now.Hour()*60+now.Minute() < out {
+			return c.JSON(http.StatusConflict, map[string]any{"error": "SCHOOL_DAY_NOT_OVER", "time_out": info.Term.TimeOut})
+		}
+	}
+
+	var by *uint
+	if p := currentPrincipal(c); p != nil && p.UserID > 0 {
+		uid := p.UserID
+		by = &uid
+	}
+	run := fillAbsences(req.Date, "manual", by)
+	if run.Error != "" {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "JOB_FAILED", "run": run})
+	}
+	return c.JSON(http.StatusOK, run)
+}
+
+// GET /attendance/absences/runs?date=
+func (h *AbsenceJobHandler) Runs(c echo.Context) error {
+	tx := database.DB.Model(&models.AttendanceJobRun{})
+	if d := strings.TrimSpace(c.QueryParam("date")); d != "" {
+		tx = tx.Where("date = ?", d)
+	}
+	var rows []models.AttendanceJobRun
+	if err := tx.Order("id DESC").Limit(100).Find(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, rows)
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/*
+	แก้ไข / ยกเลิกข้อมูลการเข้าเรียน
+
+	  - ทุกการเปลี่ยนแปลงเก็บค่าก่อน-หลังไว้ใน attendance_revisions (ใคร, เมื่อไร, เหตุผล)
+	  - ยกเลิก = ตั้ง voided_at (ไม่ลบจริง) แถวที่ยกเลิกแล้วจะไม่แสดงในรายการ/dashboard
+	  - ข้อมูลที่เก่ากว่า ATTENDANCE_EDIT_WINDOW_DAYS วัน: ผู้ที่ไม่มีสิทธิ์ attendance.admin
+	    → สร้างคำขอ (attendance_corrections) รอ admin อนุมัติก่อนมีผล
+*/
+
+const (
+	correctionUpdate = "update"
+	correctionVoid   = "void"
+
+	correctionPending  = "รออนุมัติ"
+	correctionApproved = "อนุมัติ"
+	correctionRejected = "ปฏิเสธ"
+)
+
+var (
+	errAttendanceVoided = errors.New("attendance already voided")
+	errCorrectionClosed = errors.New("correction already decided")
+)
+
+type attendanceChangeReq struct {
+	Status string `json:"status"`
+	Time   string `json:"time"` // HH:MM (ว่าง = คงเวลาเดิม)
+	Note   string `json:"note"`
+	Reason string `json:"reason"` // บังคับ
+}
+
+// แถว attendance ในขอบเขตของผู้ใช้ (นอกขอบเขต = 404)
+func (h *AttendanceHandler) findAttendance(c echo.Context) (*models.Attendance, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return nil, c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	var row models.Attendance
+	tx := scopeOf(currentPrincipal(c)).students(database.DB.Model(&models.Attendance{}), "student_id")
+	if err := tx.First(&row, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+		}
+		return nil, c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	return &row, nil
+}
+
+// ข้อมูลเก่ากว่ากำหนด → ต้องขออนุมัติ
+func (h *AttendanceHandler) needsApproval(p *Principal, row *models.Attendance) bool {
+	if p.Can(PermAttendanceAdmin) {
+		return false
+	}
+	day, err := time.ParseInLocation("2006-01-02", row.Date, time.Local)
+	if err != nil {
+		return true
+	}
+	today, _ := time.ParseInLocation("2006-01-02", time.Now().Format("2006-01-02"), time.Local)
+	return today.Sub(day) > time.Duration(h.EditWindowDays)*24*time.Hour
+}
+
+// ใช้การเปลี่ยนแปลงกับแถว (ล็อกแถว) + เขียน revision
+func (h *AttendanceHandler) applyChange(tx *gorm.DB, id uint, ch models.AttendanceCorrection, changedBy, approvedBy *uint) (*models.Attendance, error) {
+	var row models.Attendance
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&row, id).Error; err != nil {
+		return nil, err
+	}
+	if row.VoidedAt != nil {
+		return nil, errAttendanceVoided
+	}
+	rev := models.AttendanceRevision{
+		AttendanceID: row.ID,
+		Action:       ch.Action,
+		OldStatus:    row.Status,
+		OldTime:      row.Time,
+		OldNote:      row.Note,
+		Reason:       ch.Reason,
+		ChangedBy:    changedBy,
+		ApprovedBy:   approvedBy,
+		ChangedAt:    time.Now(),
+	}
+	if ch.ID != 0 {
+		cid := ch.ID
+		rev.CorrectionID = &cid
+	}
+
+	if ch.Action == correctionVoid {
+		now := time.Now()
+		row.VoidedAt = &now
+		rev.NewStatus, rev.NewTime, rev.NewNote = row.Status, row.Time, row.Note
+		if err := tx.Model(&row).Update("voided_at", &now).Error; err != nil {
+			return nil, err
+		}
+	} else {
+		row.Status = ch.NewStatus
+		row.Note = ch.NewNote
+		if ch.NewTime != "" {
+			row.Time = ch.NewTime
+		}
+		if !statusHasTime(row.Status) {
+			row.Time = "—"
+		}
+		// คำนวณสาย/ออกก่อนใหม่ตามสถานะ/เวลาใหม่
+		row.MinutesLate, row.EarlyLeave, row.MinutesEarly = 0, false, 0
+		h.scheduleOn(row.Date).apply(&row)
+		row.StatusCode = attendanceStatusCode(row.Status)
+		rev.NewStatus, rev.NewTime, rev.NewNote = row.Status, row.Time, row.Note
+		updates := map[string]any{
+			"status": row.Status, "status_code": row.StatusCode, "time": row.Time, "note": row.Note,
+			"minutes_late": row.MinutesLate, "early_leave": row.EarlyLeave, "minutes_early": row.MinutesEarly,
+		}
+		// แถว "ลา" จากใบลาที่ถูกแก้เป็นสถานะอื่น → ไม่ผูกกับใบลาอีก (ยกเลิกใบลาภายหลังจะไม่แตะแถวนี้)
+		if row.LeaveRequestID != nil && row.StatusCode != statusCodeLeave {
+			row.LeaveRequestID = nil
+			updates["leave_request_id"] = nil
+		}
+		if err := tx.Model(&row).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Create(&rev).Error; err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// แก้ทันที หรือสร้างคำขอรออนุมัติ (ข้อมูลเก่าเกินกำหนด)
+func (h *AttendanceHandler) submitChange(c echo.Context, row *models.Attendance, ch models.AttendanceCorrection) error {
+	p := currentPrincipal(c)
+	if row.VoidedAt != nil {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "ATTENDANCE_VOIDED"})
+	}
+	var pending int64
+	if err := database.DB.Model(&models.AttendanceCorrection{}).
+		Where("attendance_id = ? AND status = ?", row.ID, correctionPending).
+		Count(&pending).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	if pending > 0 {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "CORRECTION_PENDING"})
+	}
+
+	var by *uint
+	if p != nil && p.UserID > 0 {
+		uid := p.UserID
+		by = &uid
+	}
+
+	if h.needsApproval(p, row) {
+		ch.AttendanceID = row.ID
+		ch.Status = correctionPending
+		if by != nil {
+			ch.RequestedBy = *by
+		}
+		if err := database.DB.Create(&ch).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+		}
+		return c.JSON(http.StatusAccepted, map[string]any{"pending": true, "correction": ch})
+	}
+
+	var updated *models.Attendance
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		updated, err = h.applyChange(tx, row.ID, ch, by, nil)
+		return err
+	})
+	switch {
+	case err == errAttendanceVoided:
+		return c.JSON(http.StatusConflict, map[string]any{"error": "ATTENDANCE_VOIDED"})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"pending": false, "attendance": updated})
+}
+
+/* ====================== Handlers ====================== */
+
+// PUT /attendance/:id
+// body: { status, time?, note?, reason }
+func (h *AttendanceHandler) Update(c echo.Context) error {
+	row, err := h.findAttendance(c)
+	if row == nil {
+		return err
+	}
+	var req attendanceChangeReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	fields := map[string]string{}
+	status, note, ok := normalizeAttendanceStatus(req.Status, req.Note)
+	if !ok {
+		fields["status"] = "invalid"
+	}
+	tm := strings.TrimSpace(req.Time)
+	if tm != "" {
+		if _, err := time.Parse("15:04", tm); err != nil {
+			fields["time"] = "HH:MM"
+		}
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		fields["reason"] = "required"
+	}
+	if len(fields) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "VALIDATION_ERROR", "fields": fields})
+	}
+	return h.submitChange(c, row, models.AttendanceCorrection{
+		Action: correctionUpdate, NewStatus: status, NewTime: tm, NewNote: note, Reason: reason,
+	})
+}
+
+// POST /attendance/:id/void
+// body: { reason }
+func (h *AttendanceHandler) Void(c echo.Context) error {
+	row, err := h.findAttendance(c)
+	if row == nil {
+		return err
+	}
+	var req attendanceChangeReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+			"error": "VALIDATION_ERROR", "fields": map[string]string{"reason": "required"},
+		})
+	}
+	return h.submitChange(c, row, models.AttendanceCorrection{Action: correctionVoid, Reason: reason})
+}
+
+// GET /attendance/:id/history — แถวปัจจุบัน + ประวัติการแก้ + คำขอแก้
+func (h *AttendanceHandler) History(c echo.Context) error {
+	row, err := h.findAttendance(c)
+	if row == nil {
+		return err
+	}
+	var revs []models.AttendanceRevision
+	if err := database.DB.Where("attendance_id = ?", row.ID).Order("id ASC").Find(&revs).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	var reqs []models.AttendanceCorrection
+	if err := database.DB.Where("attendance_id = ?", row.ID).Order("id ASC").Find(&reqs).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"attendance": row, "revisions": revs, "corrections": reqs})
+}
+
+// GET /attendance/corrections?status=รออนุมัติ&page=&size=
+func (h *AttendanceHandler) ListCorrections(c echo.Context) error {
+	page := atoiOr(c.QueryParam("page"), 1)
+	size := atoiOr(c.QueryParam("size"), 20)
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	tx := database.DB.Model(&models.AttendanceCorrection{})
+	if st := strings.TrimSpace(c.QueryParam("status")); st != "" {
+		tx = tx.Where("status = ?", st)
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_COUNT_FAILED"})
+	}
+	var rows []models.AttendanceCorrection
+	if err := tx.Order("id DESC").Limit(size).Offset((page - 1) * size).Find(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"items": rows, "page": page, "size": size, "total": total})
+}
+
+// POST /attendance/corrections/:id/approve
+func (h *AttendanceHandler) ApproveCorrection(c echo.Context) error {
+	return h.decideCorrection(c, correctionApproved, "")
+}
+
+// POST /attendance/corrections/:id/reject
+// body: { reason }
+func (h *AttendanceHandler) RejectCorrection(c echo.Context) error {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	_ = c.Bind(&req)
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "REJECT_REASON_REQUIRED"})
+	}
+	return h.decideCorrection(c, correctionRejected, reason)
+}
+
+func (h *AttendanceHandler) decideCorrection(c echo.Context, status, rejectReason string) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	p := currentPrincipal(c)
+	var by *uint
+	if p != nil && p.UserID > 0 {
+		uid := p.UserID
+		by = &uid
+	}
+
+	var cr models.AttendanceCorrection
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&cr, id).Error; err != nil {
+			return err
+		}
+		if cr.Status != correctionPending {
+			return errCorrectionClosed
+		}
+		if status == correctionApproved {
+			requester := cr.RequestedBy
+			if _, err := h.applyChange(tx, cr.AttendanceID, cr, &requester, by); err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		cr.Status, cr.DecidedBy, cr.DecidedAt, cr.RejectReason = status, by, &now, rejectReason
+		return tx.Model(&cr).Updates(map[string]any{
+			"status": status, "decided_by": by, "decided_at": &now, "reject_reason": rejectReason,
+		}).Error
+	})
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+	case err == errCorrectionClosed:
+		return c.JSON(http.StatusConflict, map[string]any{"error": "ALREADY_DECIDED", "status": cr.Status})
+	case err == errAttendanceVoided:
+		return c.JSON(http.StatusConflict, map[string]any{"error": "ATTENDANCE_VOIDED"})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusOK, cr)
+}
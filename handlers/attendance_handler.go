@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -12,12 +13,24 @@ import (
 	"github.com/patiponrmutl/BESystem/models"
 )
 
-type AttendanceHandler struct{}
+type AttendanceHandler struct {
+	LateGraceMinutes int // "เข้า" หลัง TimeIn เกินกี่นาทีถึงนับเป็น "มาสาย"
+	EditWindowDays   int // แก้/ยกเลิกข้อมูลที่เก่ากว่านี้ต้องรอ admin อนุมัติ
+}
 
-func NewAttendanceHandler() *AttendanceHandler { return &AttendanceHandler{} }
+func NewAttendanceHandler() *AttendanceHandler {
+	grace := atoiOr(os.Getenv("ATTENDANCE_LATE_GRACE_MINUTES"), 10)
+	if grace < 0 {
+		grace = 0
+	}
+	return &AttendanceHandler{
+		LateGraceMinutes: grace,
+		EditWindowDays:   atoiOr(os.Getenv("ATTENDANCE_EDIT_WINDOW_DAYS"), 7),
+	}
+}
 
-// GET /teacher/attendance?start=YYYY-MM-DD&end=YYYY-MM-DD&studentId=&statuses=เข้า,ออก,มาสาย,ขาด,ลา
-// optional: grade, room, q
+// GET /teacher/attendance?start=YYYY-MM-DD&end=YYYY-MM-DD&studentId=&statuses=เข้า,ออก,มาสาย,ขาด,ลา (หรือรหัส in,out,...)
+// optional: grade, room, q, includeVoided=1 (รวมแถวที่ถูกยกเลิก)
 func (h *AttendanceHandler) List(c echo.Context) error {
 	start := strings.TrimSpace(c.QueryParam("start"))
 	end := strings.TrimSpace(c.QueryParam("end"))
@@ -27,7 +40,10 @@ func (h *AttendanceHandler) List(c echo.Context) error {
 	room := strings.TrimSpace(c.QueryParam("room"))
 	q := strings.TrimSpace(c.QueryParam("q"))
 
-	tx := database.DB.Model(&models.Attendance{})
+	tx := scopeOf(currentPrincipal(c)).students(database.DB.Model(&models.Attendance{}), "attendances.student_id")
+	if c.QueryParam("includeVoided") != "1" {
+		tx = tx.Where("attendances.voided_at IS NULL")
+	}
 
 	if start != "" && end != "" {
 		tx = tx.Where(`date >= ? AND date <= ?`, start, end)
@@ -36,7 +52,11 @@ func (h *AttendanceHandler) List(c echo.Context) error {
 		tx = tx.Where("student_id = ?", studentID)
 	}
 	if statuses != "" {
+		// รับได้ทั้งชื่อไทยและรหัส (in,late,...)
 		parts := splitCSV(statuses)
+		for i, s := range parts {
+			parts[i] = attendanceStatusLabel(s)
+		}
 		if len(parts) > 0 {
 			tx = tx.Where("status IN ?", parts)
 		}
@@ -68,64 +88,110 @@ func (h *AttendanceHandler) List(c echo.Context) error {
 	return c.JSON(http.StatusOK, rows)
 }
 
-func (h *AttendanceHandler) Mark(c echo.Context) error {
-	var req struct {
-		StudentID uint   `json:"student_id"`
-		Date      string `json:"date"`
-		Status    string `json:"status"`
-		Note      string `json:"note"`
-		Operator  string `json:"operator"` // เก็บลง Note เพิ่ม/หรือช่องแยก ถ้ามีคอลัมน์
-		Retro     bool   `json:"retro"`
+// ข้อมูลการเช็คชื่อ 1 รายการ (ใช้ทั้งเช็ครายคนและเช็คทั้งห้อง)
+type markInput struct {
+	StudentID uint   `json:"student_id"`
+	Date      string `json:"date"`
+	Status    string `json:"status"`
+	Note      string `json:"note"`
+	Time      string `json:"time"`  // HH:MM (optional; ใช้กับการบันทึกย้อนหลัง — ว่าง = เวลาปัจจุบัน)
+	Retro     bool   `json:"retro"` // ผู้บันทึกระบุเองว่าย้อนหลัง (วันที่ผ่านมาแล้วจะถือเป็นย้อนหลังเสมอ)
+}
+
+// ตรวจ + แปลงเป็นแถว Attendance (ยังไม่บันทึก) คืน error code ถ้าข้อมูลไม่ถูกต้อง
+func buildAttendance(in markInput, p *Principal, now time.Time, sched termSchedule) (models.Attendance, string) {
+	if in.StudentID == 0 || in.Date == "" || in.Status == "" {
+		return models.Attendance{}, "MISSING_FIELDS"
 	}
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	day, err := time.ParseInLocation("2006-01-02", in.Date, now.Location())
+	if err != nil {
+		return models.Attendance{}, "INVALID_DATE"
 	}
-	if req.StudentID == 0 || req.Date == "" || req.Status == "" {
-		return c.JSON(http.StatusBadRequest, map[string]any{"error": "MISSING_FIELDS"})
+	if day.After(now) {
+		return models.Attendance{}, "DATE_IN_FUTURE"
 	}
-
-	// map "ลากิจ/ลาป่วย" → เก็บเป็น "ลา" + note แยก (ให้เข้ากับ FE ที่รวมเป็น 'ลา')
-	status := strings.TrimSpace(req.Status)
-	note := strings.TrimSpace(req.Note)
-	if status == "ลากิจ" {
-		status = "ลา"
-		if note == "" {
-			note = "ธุระส่วนตัว"
-		}
-	} else if status == "ลาป่วย" {
-		status = "ลา"
-		if note == "" {
-			note = "ป่วย"
+	at := now.Format("15:04")
+	if t := strings.TrimSpace(in.Time); t != "" {
+		if _, err := time.Parse("15:04", t); err != nil {
+			return models.Attendance{}, "INVALID_TIME"
 		}
+		at = t
+	}
+	if in.Date == now.Format("2006-01-02") && at > now.Format("15:04") {
+		return models.Attendance{}, "TIME_IN_FUTURE"
+	}
+
+	status, note, ok := normalizeAttendanceStatus(in.Status, in.Note)
+	if !ok {
+		return models.Attendance{}, "INVALID_STATUS"
 	}
 
 	// ออกแบบ: 1 วัน/นักเรียน อนุญาตหลายแถว (เข้า/ออก) → ที่ Dashboard เราจะดึง “ล่าสุด” อยู่แล้ว
 	rec := models.Attendance{
-		StudentID: req.StudentID,
-		Date:      req.Date,
-		Time:      time.Now().Format("15:04"),
-		Status:    status,
-		Note:      note,
+		StudentID:  in.StudentID,
+		Date:       in.Date,
+		Time:       at,
+		Status:     status,
+		Note:       note,
+		Retro:      in.Retro || in.Date != now.Format("2006-01-02") || at != now.Format("15:04"),
+		RecordedAt: now,
+		Source:     "manual",
 	}
-	if status == "ขาด" || status == "ลา" || status == "ยังไม่เข้าโรงเรียน" {
+	if p != nil && p.UserID > 0 {
+		uid := p.UserID
+		rec.RecordedBy = &uid
+	}
+	if !statusHasTime(status) {
 		rec.Time = "—"
 	}
+	sched.apply(&rec)
+	rec.StatusCode = attendanceStatusCode(rec.Status)
+	return rec, ""
+}
 
-	if err := database.DB.Create(&rec).Error; err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+// รูปแบบที่หน้า Dashboard ใช้
+func attendanceDTO(rec models.Attendance, p *Principal) map[string]any {
+	operator := ""
+	if p != nil {
+		operator = p.Name
 	}
+	return map[string]any{
+		"id":            rec.ID,
+		"student_id":    rec.StudentID,
+		"status":        rec.Status,
+		"status_code":   rec.StatusCode,
+		"time":          rec.Time,
+		"note":          rec.Note,
+		"operator":      operator,
+		"recorded_by":   rec.RecordedBy,
+		"recorded_at":   rec.RecordedAt,
+		"retro":         rec.Retro,
+		"minutes_late":  rec.MinutesLate,
+		"early_leave":   rec.EarlyLeave,
+		"minutes_early": rec.MinutesEarly,
+	}
+}
 
-	// ตอบกลับรูปแบบที่หน้า Dashboard ใช้
-	out := map[string]any{
-		"id":         rec.ID,
-		"student_id": rec.StudentID,
-		"status":     rec.Status,
-		"time":       rec.Time,
-		"note":       rec.Note,
-		"operator":   strings.TrimSpace(req.Operator),
-		"retro":      req.Retro,
+// POST /attendance — เช็คชื่อทีละคน
+func (h *AttendanceHandler) Mark(c echo.Context) error {
+	var req markInput
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	p := currentPrincipal(c)
+	rec, code := buildAttendance(req, p, time.Now(), h.scheduleOn(req.Date))
+	if code != "" {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": code})
+	}
+	// นักเรียนนอกห้องที่ดูแล → 404 เหมือนไม่มีนักเรียนคนนี้
+	if !scopeOf(p).allowsStudent(req.StudentID) {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "STUDENT_NOT_FOUND"})
+	}
+
+	if err := database.DB.Create(&rec).Error; err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
 	}
-	return c.JSON(http.StatusOK, out)
+	return c.JSON(http.StatusOK, attendanceDTO(rec, p))
 }
 
 func splitCSV(s string) []string {
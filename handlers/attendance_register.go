@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+	"github.com/patiponrmutl/BESystem/report"
+)
+
+/*
+	แบบบันทึกเวลาเรียนรายเดือน (ต่อห้อง)
+
+	  - แถว = นักเรียนในห้อง (เรียงตามรหัส), คอลัมน์ = ทุกวันของเดือน
+	  - วันที่ไม่ใช่วันเรียน (เสาร์-อาทิตย์/วันหยุด/นอกภาคเรียน) แรเงา ไม่ใส่เครื่องหมาย
+	  - เครื่องหมายมาจากสถานะรายวันเดียวกับ /attendance/stats (รวมใบลาที่อนุมัติ)
+	  - รวมท้ายแถว: มา / สาย / ลา / ขาด (นับเฉพาะวันเรียนที่ผ่านมาแล้ว)
+*/
+
+type AttendanceRegisterHandler struct {
+	FontPath string // TTF ภาษาไทยสำหรับ PDF
+}
+
+func NewAttendanceRegisterHandler() *AttendanceRegisterHandler {
+	return &AttendanceRegisterHandler{FontPath: strings.TrimSpace(os.Getenv("REPORT_FONT_PATH"))}
+}
+
+var registerMarks = map[string]string{
+	dayPresent: report.MarkPresent,
+	dayLate:    report.MarkLate,
+	dayLeave:   report.MarkLeave,
+	dayAbsent:  report.MarkAbsent,
+}
+
+var nonSchoolDayNotes = map[string]string{
+	"weekend": "เสาร์-อาทิตย์",
+	"no_term": "นอกภาคเรียน",
+}
+
+func buildRegister(grade, room string, month time.Time) (*report.Register, error) {
+	from := month.Format("2006-01-02")
+	to := month.AddDate(0, 1, -1).Format("2006-01-02")
+	today := time.Now().Format("2006-01-02")
+
+	reg := &report.Register{
+		Grade:      grade,
+		Room:       room,
+		MonthLabel: report.ThaiMonth(month.Year(), int(month.Month())),
+	}
+	var school models.School
+	if err := database.DB.Order("id").Limit(1).Find(&school).Error; err == nil {
+		reg.School = strings.TrimSpace(school.SchoolName)
+	}
+
+	cal := loadSchoolCalendar(from, to)
+	for d := month; d.Month() == month.Month(); d = d.AddDate(0, 0, 1) {
+		info := cal.day(d.Format("2006-01-02"))
+		day := report.Day{Date: info.Date, Day: d.Day(), SchoolDay: info.SchoolDay}
+		if !info.SchoolDay {
+			day.Note = nonSchoolDayNotes[info.Reason]
+			if info.Reason == "holiday" {
+				day.Note = info.Holiday
+			}
+		} else {
+			reg.SchoolDays++
+		}
+		reg.Days = append(reg.Days, day)
+	}
+
+	// เฉพาะนักเรียนที่ยังเรียนอยู่ (ลาออก/จบ/ย้ายออก ไม่อยู่ในแบบบันทึก)
+	var students []models.Student
+	if err := database.DB.
+		Where("grade = ? AND room = ?", grade, room).
+		Where("status NOT IN ?", inactiveStudentStatuses).
+		Order("student_id, id").
+		Find(&students).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(students))
+	for _, s := range students {
+		ids = append(ids, s.ID)
+	}
+	kinds, err := loadDayKinds(ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, s := range students {
+		row := report.Row{No: i + 1, Code: s.StudentID, Name: studentFullName(s), Marks: make([]string, len(reg.Days))}
+		for j, d := range reg.Days {
+			if !d.SchoolDay || d.Date > today {
+				continue
+			}
+			k := kinds[s.ID][d.Date]
+			row.Marks[j] = registerMarks[k]
+			switch k {
+			case dayPresent:
+				row.Totals.Present++
+			case dayLate:
+				row.Totals.Late++
+			case dayLeave:
+				row.Totals.Leave++
+			case dayAbsent:
+				row.Totals.Absent++
+			}
+		}
+		reg.Rows = append(reg.Rows, row)
+	}
+	return reg, nil
+}
+
+// GET /attendance/register?grade=&room=&month=YYYY-MM&format=csv|xlsx|pdf
+func (h *AttendanceRegisterHandler) Export(c echo.Context) error {
+	grade := strings.TrimSpace(c.QueryParam("grade"))
+	room := strings.TrimSpace(c.QueryParam("room"))
+	if grade == "" || room == "" {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "GRADE_ROOM_REQUIRED"})
+	}
+	ym := strings.TrimSpace(c.QueryParam("month"))
+	if ym == "" {
+		ym = time.Now().Format("2006-01")
+	}
+	month, err := time.ParseInLocation("2006-01", ym, time.Local)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_MONTH"})
+	}
+	format := strings.ToLower(strings.TrimSpace(c.QueryParam("format")))
+	if format == "" {
+		format = "xlsx"
+	}
+	if format != "csv" && format != "xlsx" && format != "pdf" {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_FORMAT"})
+	}
+	if format == "pdf" && h.FontPath == "" {
+		return c.JSON(http.StatusNotImplemented, map[string]any{"error": "PDF_FONT_NOT_CONFIGURED"})
+	}
+
+	// ห้องนอกขอบเขต → 404 (เหมือนรายการอื่น ๆ)
+	if !scopeOf(currentPrincipal(c)).allows(grade, room) {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "CLASS_NOT_FOUND"})
+	}
+
+	reg, err := buildRegister(grade, room, month)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+
+	var buf bytes.Buffer
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+		err = reg.WriteCSV(&buf)
+	case "xlsx":
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		err = reg.WriteXLSX(&buf)
+	case "pdf":
+		contentType = "application/pdf"
+		err = reg.WritePDF(&buf, h.FontPath)
+	}
+	if errors.Is(err, report.ErrNoFont) {
+		return c.JSON(http.StatusNotImplemented, map[string]any{"error": "PDF_FONT_NOT_CONFIGURED"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "EXPORT_FAILED"})
+	}
+
+	filename := fmt.Sprintf("register_%s-%s_%s.%s", grade, room, ym, format)
+	// ชั้น/ห้องอาจเป็นภาษาไทย → filename* (RFC 5987) + ชื่อสำรอง ASCII
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf("attachment; filename=\"register_%s.%s\"; filename*=UTF-8''%s", ym, format, url.PathEscape(filename)))
+	return c.Blob(http.StatusOK, contentType, buf.Bytes())
+}
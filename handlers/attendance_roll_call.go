@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/*
+	เช็คชื่อทั้งห้องในครั้งเดียว
+
+	POST /attendance/roll-call
+	header (แนะนำ): Idempotency-Key: <uuid ที่ FE สร้างต่อการกดส่ง 1 ครั้ง>
+	body: {
+	  grade, room, date,
+	  entries: [{ student_id, status, note?, time?, retro? }]
+	}
+
+	- นักเรียนทุกคนใน entries ต้องอยู่ในชั้น/ห้องนี้ ไม่งั้นไม่บันทึกเลยสักคน (422 + ผลรายคน)
+	- บันทึกทั้งหมดใน transaction เดียว
+	- ส่ง key เดิม + ข้อมูลเดิมซ้ำ (เน็ตหลุดแล้วกดใหม่) → ได้ผลเดิม ไม่บันทึกซ้ำ
+	  ส่ง key เดิมแต่ข้อมูลเปลี่ยน → 409
+*/
+
+const maxRollCallEntries = 200
+
+type rollCallReq struct {
+	Grade          string      `json:"grade"`
+	Room           string      `json:"room"`
+	Date           string      `json:"date"`
+	IdempotencyKey string      `json:"idempotency_key"` // ใช้เมื่อส่ง header ไม่ได้
+	Entries        []markInput `json:"entries"`
+}
+
+type rollCallResult struct {
+	StudentID uint   `json:"student_id"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+	ID        uint   `json:"id,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Retro     bool   `json:"retro,omitempty"`
+
+	MinutesLate  int  `json:"minutes_late,omitempty"`
+	EarlyLeave   bool `json:"early_leave,omitempty"`
+	MinutesEarly int  `json:"minutes_early,omitempty"`
+}
+
+type rollCallResp struct {
+	SubmissionID uint             `json:"submission_id"`
+	Grade        string           `json:"grade"`
+	Room         string           `json:"room"`
+	Date         string           `json:"date"`
+	Count        int              `json:"count"`
+	Results      []rollCallResult `json:"results"`
+}
+
+// ตอบผลของการส่งครั้งก่อน (ไม่บันทึกซ้ำ)
+func replayRollCall(c echo.Context, sub *models.AttendanceSubmission) error {
+	c.Response().Header().Set("Idempotent-Replayed", "true")
+	return c.JSONBlob(http.StatusCreated, []byte(sub.Response))
+}
+
+func findSubmission(userID uint, key string) (*models.AttendanceSubmission, error) {
+	var sub models.AttendanceSubmission
+	err := database.DB.Where("user_id = ? AND key = ?", userID, key).First(&sub).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// POST /attendance/roll-call
+func (h *AttendanceHandler) RollCall(c echo.Context) error {
+	var req rollCallReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	req.Grade = strings.TrimSpace(req.Grade)
+	req.Room = strings.TrimSpace(req.Room)
+	req.Date = strings.TrimSpace(req.Date)
+	if req.Grade == "" || req.Room == "" || req.Date == "" || len(req.Entries) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "MISSING_FIELDS"})
+	}
+	if len(req.Entries) > maxRollCallEntries {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "TOO_MANY_ENTRIES", "max": maxRollCallEntries})
+	}
+
+	p := currentPrincipal(c)
+	if p == nil || p.UserID == 0 {
+		return c.JSON(http.StatusForbidden, map[string]any{"error": "FORBIDDEN"})
+	}
+	// ห้องที่ไม่ได้ดูแล → 404 เหมือนไม่มีห้องนี้
+	if !scopeOf(p).allows(req.Grade, req.Room) {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "CLASS_NOT_FOUND"})
+	}
+
+	// ===== Idempotency =====
+	key := strings.TrimSpace(c.Request().Header.Get("Idempotency-Key"))
+	if key == "" {
+		key = strings.TrimSpace(req.IdempotencyKey)
+	}
+	if len(key) > 100 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_IDEMPOTENCY_KEY"})
+	}
+	req.IdempotencyKey = ""
+	payload, _ := json.Marshal(req)
+	reqHash := hashToken(string(payload))
+	if key != "" {
+		sub, err := findSubmission(p.UserID, key)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+		}
+		if sub != nil {
+			if sub.RequestHash != reqHash {
+				return c.JSON(http.StatusConflict, map[string]any{"error": "IDEMPOTENCY_KEY_REUSED"})
+			}
+			return replayRollCall(c, sub)
+		}
+	}
+
+	// ===== ตรวจรายคน =====
+	// นักเรียนที่ลาออก/จบ/พักการเรียน ไม่นับเป็นสมาชิกห้อง (เหมือน dashboard/สถิติ/แบบบันทึก)
+	var inClass []uint
+	if err := database.DB.Model(&models.Student{}).
+		Where("grade = ? AND room = ?", req.Grade, req.Room).
+		Where("status NOT IN ?", inactiveStudentStatuses).
+		Pluck("id", &inClass).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	member := make(map[uint]bool, len(inClass))
+	for _, id := range inClass {
+		member[id] = true
+	}
+
+	now := time.Now()
+	sched := h.scheduleOn(req.Date)
+	results := make([]rollCallResult, len(req.Entries))
+	rows := make([]models.Attendance, 0, len(req.Entries))
+	seen := map[uint]bool{}
+	failed := false
+	for i, in := range req.Entries {
+		in.Date = req.Date
+		results[i].StudentID = in.StudentID
+		switch {
+		case in.StudentID == 0:
+			results[i].Error = "MISSING_FIELDS"
+		case seen[in.StudentID]:
+			results[i].Error = "DUPLICATE_STUDENT"
+		case !member[in.StudentID]:
+			results[i].Error = "NOT_IN_CLASS"
+		}
+		if results[i].Error == "" {
+			rec, code := buildAttendance(in, p, now, sched)
+			if code != "" {
+				results[i].Error = code
+			} else {
+				rows = append(rows, rec)
+			}
+		}
+		seen[in.StudentID] = true
+		if results[i].Error != "" {
+			failed = true
+		}
+	}
+	if failed {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "VALIDATION_ERROR", "results": results})
+	}
+
+	// ===== บันทึกทั้งห้องใน transaction เดียว =====
+	resp := rollCallResp{Grade: req.Grade, Room: req.Room, Date: req.Date, Count: len(rows), Results: results}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rows).Error; err != nil {
+			return err
+		}
+		for i, rec := range rows {
+			resp.Results[i] = rollCallResult{
+				StudentID: rec.StudentID, OK: true, ID: rec.ID,
+				Status: rec.Status, Time: rec.Time, Retro: rec.Retro,
+				MinutesLate: rec.MinutesLate, EarlyLeave: rec.EarlyLeave, MinutesEarly: rec.MinutesEarly,
+			}
+		}
+		if key == "" {
+			return nil
+		}
+		sub := models.AttendanceSubmission{
+			UserID: p.UserID, Key: key, RequestHash: reqHash,
+			Grade: req.Grade, Room: req.Room, Date: req.Date, Count: len(rows),
+		}
+		if err := tx.Create(&sub).Error; err != nil {
+			return err
+		}
+		resp.SubmissionID = sub.ID
+		body, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&sub).Update("response", string(body)).Error
+	})
+	if err != nil {
+		// ส่ง key เดียวกันพร้อมกัน 2 ครั้ง → อีกครั้งชน unique index; ตอบผลของครั้งที่สำเร็จ
+		if key != "" {
+			if sub, _ := findSubmission(p.UserID, key); sub != nil {
+				if sub.RequestHash != reqHash {
+					return c.JSON(http.StatusConflict, map[string]any{"error": "IDEMPOTENCY_KEY_REUSED"})
+				}
+				return replayRollCall(c, sub)
+			}
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusCreated, resp)
+}
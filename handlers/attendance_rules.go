@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/*
+	เวลาเข้า-ออกตามภาคเรียน (calendar type normal ที่ครอบคลุมวันนั้น)
+
+	  - "เข้า" หลัง TimeIn + ผ่อนผัน (ATTENDANCE_LATE_GRACE_MINUTES) → เปลี่ยนเป็น "มาสาย"
+	    นาทีที่สายนับจาก TimeIn (ไม่หักช่วงผ่อนผัน)
+	  - "ออก" ก่อน TimeOut → ติดธง early_leave พร้อมจำนวนนาที
+	  - วันที่ไม่มีภาคเรียน/ไม่ได้ตั้งเวลา → ไม่ตรวจ
+*/
+
+type termSchedule struct {
+	TimeIn  string // HH:MM ("" = ไม่ตรวจสาย)
+	TimeOut string // HH:MM ("" = ไม่ตรวจออกก่อน)
+	Grace   int    // นาที
+}
+
+// ภาคเรียนที่ครอบคลุมวันที่ date (YYYY-MM-DD)
+func (h *AttendanceHandler) scheduleOn(date string) termSchedule {
+	s := termSchedule{Grace: h.LateGraceMinutes}
+	var term models.CalendarItem
+	if err := database.DB.
+		Where("type = ? AND open_date <= ? AND close_date >= ?", "normal", date, date).
+		Order("open_date DESC").
+		First(&term).Error; err != nil {
+		return s
+	}
+	s.TimeIn = strings.TrimSpace(term.TimeIn)
+	s.TimeOut = strings.TrimSpace(term.TimeOut)
+	return s
+}
+
+// "HH:MM" → นาทีนับจากเที่ยงคืน
+func minuteOfDay(hhmm string) (int, bool) {
+	parts := strings.Split(strings.TrimSpace(hhmm), ":")
+	if len(parts) != 2 {
+		return 0, false
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, false
+	}
+	return h*60 + m, true
+}
+
+// ปรับสถานะ/นาทีสาย/ออกก่อน ตามเวลาของภาคเรียน (rec.Time ต้องเป็น HH:MM)
+func (s termSchedule) apply(rec *models.Attendance) {
+	at, ok := minuteOfDay(rec.Time)
+	if !ok {
+		return
+	}
+	switch rec.Status {
+	case "เข้า", "มาสาย":
+		in, ok := minuteOfDay(s.TimeIn)
+		if !ok {
+			return
+		}
+		if late := at - in; late > 0 {
+			rec.MinutesLate = late
+			if late > s.Grace {
+				rec.Status = "มาสาย"
+			}
+		}
+		// "มาสาย" ที่ครูเลือกเองคงไว้ แม้ยังอยู่ในช่วงผ่อนผัน
+	case "ออก":
+		out, ok := minuteOfDay(s.TimeOut)
+		if !ok {
+			return
+		}
+		if early := out - at; early > 0 {
+			rec.EarlyLeave = true
+			rec.MinutesEarly = early
+		}
+	}
+}
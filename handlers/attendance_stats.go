@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/*
+	สถิติการเข้าเรียนรายคน (นับเฉพาะวันเรียนตามปฏิทิน)
+
+	สถานะของนักเรียนต่อวัน (มีหลายแถวในวันเดียว → เลือกตามลำดับ; ตามหมวดใน attendance_statuses):
+	  มาสาย > มา (สถานะที่นับว่ามาเรียน) > ลา (หมวด leave — ใบลาที่อนุมัติเขียนเป็นแถว "ลา" แล้ว) > ขาด > ไม่มีข้อมูล
+
+	อัตราการมาเรียน = (มา + มาสาย) / จำนวนวันเรียน × 100
+	กลุ่มเสี่ยง = อัตราต่ำกว่า ATTENDANCE_RISK_RATE หรือขาดติดต่อกันตั้งแต่ ATTENDANCE_RISK_CONSECUTIVE วัน
+*/
+
+const (
+	dayPresent    = "present"
+	dayLate       = "late"
+	dayLeave      = "leave"
+	dayAbsent     = "absent"
+	dayUnrecorded = ""
+)
+
+var dayRank = map[string]int{dayLate: 4, dayPresent: 3, dayLeave: 2, dayAbsent: 1}
+
+// ตาม catalogue: late → สาย, นับว่ามาเรียน → มา, แล้วจึงดูหมวด leave/absent
+func dayKindOf(status string) string {
+	st, ok := findAttendanceStatus(status)
+	switch {
+	case !ok:
+		return dayUnrecorded
+	case st.Code == statusCodeLate:
+		return dayLate
+	case st.CountsAsPresent:
+		return dayPresent
+	case st.Category == statusCategoryLeave:
+		return dayLeave
+	case st.Category == statusCategoryAbsent:
+		return dayAbsent
+	}
+	return dayUnrecorded
+}
+
+type AttendanceStatsHandler struct {
+	RiskRate        float64 // %
+	RiskConsecutive int     // วัน
+}
+
+func NewAttendanceStatsHandler() *AttendanceStatsHandler {
+	rate, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("ATTENDANCE_RISK_RATE")), 64)
+	if err != nil || rate <= 0 || rate > 100 {
+		rate = 80
+	}
+	return &AttendanceStatsHandler{
+		RiskRate:        rate,
+		RiskConsecutive: atoiOr(os.Getenv("ATTENDANCE_RISK_CONSECUTIVE"), 3),
+	}
+}
+
+/* ====================== ช่วงวันที่ ====================== */
+
+type statsRange struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label"` // term:<id> | month:YYYY-MM | custom
+}
+
+// ?term=<calendar id> | ?month=YYYY-MM | ?from=&to= | (ว่าง = ภาคเรียนปัจจุบัน หรือเดือนนี้)
+// ตัดปลายไม่ให้เกินวันนี้ (วันในอนาคตยังไม่นับ)
+func resolveStatsRange(c echo.Context) (statsRange, string) {
+	var r statsRange
+	today := time.Now().Format("2006-01-02")
+	month := func(ym string) bool {
+		m, err := time.Parse("2006-01", ym)
+		if err != nil {
+			return false
+		}
+		r.From = m.Format("2006-01-02")
+		r.To = m.AddDate(0, 1, -1).Format("2006-01-02")
+		r.Label = "month:" + ym
+		return true
+	}
+
+	switch {
+	case strings.TrimSpace(c.QueryParam("term")) != "":
+		var term models.CalendarItem
+		if err := database.DB.First(&term, "id = ? AND type = ?", c.QueryParam("term"), "normal").Error; err != nil {
+			return r, "TERM_NOT_FOUND"
+		}
+		r.From, r.To, r.Label = term.OpenDate, term.CloseDate, "term:"+strconv.FormatUint(uint64(term.ID), 10)
+	case strings.TrimSpace(c.QueryParam("month")) != "":
+		if !month(strings.TrimSpace(c.QueryParam("month"))) {
+			return r, "INVALID_MONTH"
+		}
+	case c.QueryParam("from") != "" || c.QueryParam("to") != "":
+		r.From, r.To, r.Label = strings.TrimSpace(c.QueryParam("from")), strings.TrimSpace(c.QueryParam("to")), "custom"
+		if !isDateYYYYMMDD(r.From) || !isDateYYYYMMDD(r.To) || r.From > r.To {
+			return r, "INVALID_RANGE"
+		}
+	default:
+		if info := schoolDayOf(today); info.Term != nil {
+			r.From, r.To, r.Label = info.Term.OpenDate, info.Term.CloseDate, "term:"+strconv.FormatUint(uint64(info.Term.ID), 10)
+		} else {
+			month(time.Now().Format("2006-01"))
+		}
+	}
+	if r.To > today {
+		r.To = today
+	}
+	return r, ""
+}
+
+/* ====================== สถานะรายวัน ====================== */
+
+// สถานะต่อวันของนักเรียนแต่ละคน: [student_id][date] = present|late|leave|absent
+func loadDayKinds(studentIDs []uint, from, to string) (map[uint]map[string]string, error) {
+	out := make(map[uint]map[string]string, len(studentIDs))
+	if len(studentIDs) == 0 {
+		return out, nil
+	}
+	set := func(sid uint, date, kind string) {
+		if out[sid] == nil {
+			out[sid] = map[string]string{}
+		}
+		if dayRank[kind] > dayRank[out[sid][date]] {
+			out[sid][date] = kind
+		}
+	}
+
+	var rows []struct {
+		StudentID uint
+		Date      string
+		Status    string
+	}
+	if err := database.DB.Model(&models.Attendance{}).
+		Select("student_id, date, status").
+		Where("student_id IN ? AND date >= ? AND date <= ? AND voided_at IS NULL", studentIDs, from, to).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		if k := dayKindOf(r.Status); k != dayUnrecorded {
+			set(r.StudentID, r.Date, k)
+		}
+	}
+
+	return out, nil
+}
+
+/* ====================== สถิติ ====================== */
+
+type studentStats struct {
+	StudentID  uint   `json:"student_id"`
+	Code       string `json:"code"`
+	FullName   string `json:"full_name"`
+	Grade      string `json:"grade"`
+	Room       string `json:"room"`
+	SchoolDays int    `json:"school_days"`
+	Present    int    `json:"present"`
+	Late       int    `json:"late"`
+	Leave      int    `json:"leave"`
+	Absent     int    `json:"absent"`
+	Unrecorded int    `json:"unrecorded"`
+
+	AttendanceRate           float64  `json:"attendance_rate"` // %
+	MaxConsecutiveAbsent     int      `json:"max_consecutive_absent"`
+	CurrentConsecutiveAbsent int      `json:"current_consecutive_absent"` // นับย้อนจากวันเรียนล่าสุดในช่วง
+	AtRisk                   bool     `json:"at_risk"`
+	RiskReasons              []string `json:"risk_reasons,omitempty"` // low_rate | consecutive_absent
+}
+
+func studentFullName(s models.Student) string {
+	return strings.Join(strings.Fields(s.Prefix+" "+s.FirstName+" "+s.LastName), " ")
+}
+
+// นักเรียนในขอบเขต (ยังเรียนอยู่) + filter grade/room/studentId
+func statsStudents(c echo.Context) ([]models.Student, error) {
+	tx := scopeOf(currentPrincipal(c)).where(database.DB.Model(&models.Student{}), "grade", "room").
+		Where("status NOT IN ?", inactiveStudentStatuses)
+	if g := strings.TrimSpace(c.QueryParam("grade")); g != "" {
+		tx = tx.Where("grade = ?", g)
+	}
+	if r := strings.TrimSpace(c.QueryParam("room")); r != "" {
+		tx = tx.Where("room = ?", r)
+	}
+	if sid := strings.TrimSpace(c.QueryParam("studentId")); sid != "" {
+		tx = tx.Where("id = ?", sid)
+	}
+	var rows []models.Student
+	err := tx.Order("grade, room, student_id").Find(&rows).Error
+	return rows, err
+}
+
+func (h *AttendanceStatsHandler) compute(c echo.Context, rateMin float64, consecMin int) (statsRange, []string, []studentStats, string) {
+	rng, code := resolveStatsRange(c)
+	if code != "" {
+		return rng, nil, nil, code
+	}
+	days := loadSchoolCalendar(rng.From, rng.To).schoolDays(rng.From, rng.To)
+
+	students, err := statsStudents(c)
+	if err != nil {
+		return rng, nil, nil, "DB_QUERY_FAILED"
+	}
+	ids := make([]uint, 0, len(students))
+	for _, s := range students {
+		ids = append(ids, s.ID)
+	}
+	kinds, err := loadDayKinds(ids, rng.From, rng.To)
+	if err != nil {
+		return rng, nil, nil, "DB_QUERY_FAILED"
+	}
+
+	out := make([]studentStats, 0, len(students))
+	for _, s := range students {
+		st := studentStats{
+			StudentID: s.ID, Code: s.StudentID, FullName: studentFullName(s),
+			Grade: s.Grade, Room: s.Room, SchoolDays: len(days),
+		}
+		streak := 0
+		for _, d := range days {
+			k := kinds[s.ID][d]
+			switch k {
+			case dayPresent:
+				st.Present++
+			case dayLate:
+				st.Late++
+			case dayLeave:
+				st.Leave++
+			case dayAbsent:
+				st.Absent++
+			default:
+				st.Unrecorded++
+			}
+			if k == dayAbsent {
+				streak++
+				if streak > st.MaxConsecutiveAbsent {
+					st.MaxConsecutiveAbsent = streak
+				}
+			} else {
+				streak = 0
+			}
+		}
+		st.CurrentConsecutiveAbsent = streak
+		if len(days) > 0 {
+			st.AttendanceRate = float64(int(float64(st.Present+st.Late)/float64(len(days))*10000+0.5)) / 100
+			if st.AttendanceRate < rateMin {
+				st.RiskReasons = append(st.RiskReasons, "low_rate")
+			}
+		}
+		if consecMin > 0 && st.MaxConsecutiveAbsent >= consecMin {
+			st.RiskReasons = append(st.RiskReasons, "consecutive_absent")
+		}
+		st.AtRisk = len(st.RiskReasons) > 0
+		out = append(out, st)
+	}
+	return rng, days, out, ""
+}
+
+// เกณฑ์กลุ่มเสี่ยง: ?threshold=<%>&consecutive=<วัน> (ไม่ส่ง = ค่าจาก env)
+func (h *AttendanceStatsHandler) thresholds(c echo.Context) (float64, int) {
+	rate := h.RiskRate
+	if v, err := strconv.ParseFloat(c.QueryParam("threshold"), 64); err == nil && v > 0 && v <= 100 {
+		rate = v
+	}
+	consec := h.RiskConsecutive
+	if v, err := strconv.Atoi(c.QueryParam("consecutive")); err == nil && v > 0 {
+		consec = v
+	}
+	return rate, consec
+}
+
+func statsError(c echo.Context, code string) error {
+	if code == "DB_QUERY_FAILED" {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": code})
+	}
+	if code == "TERM_NOT_FOUND" {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": code})
+	}
+	return c.JSON(http.StatusBadRequest, map[string]any{"error": code})
+}
+
+// GET /attendance/stats?term=|month=|from=&to=&grade=&room=&studentId=&threshold=&consecutive=
+func (h *AttendanceStatsHandler) Stats(c echo.Context) error {
+	rate, consec := h.thresholds(c)
+	rng, days, items, code := h.compute(c, rate, consec)
+	if code != "" {
+		return statsError(c, code)
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"range":       rng,
+		"school_days": len(days),
+		"threshold":   map[string]any{"rate": rate, "consecutive": consec},
+		"items":       items,
+	})
+}
+
+// GET /attendance/stats/at-risk — เฉพาะนักเรียนกลุ่มเสี่ยง (พารามิเตอร์เดียวกับ /attendance/stats)
+func (h *AttendanceStatsHandler) AtRisk(c echo.Context) error {
+	rate, consec := h.thresholds(c)
+	rng, days, items, code := h.compute(c, rate, consec)
+	if code != "" {
+		return statsError(c, code)
+	}
+	out := make([]studentStats, 0)
+	for _, st := range items {
+		if st.AtRisk {
+			out = append(out, st)
+		}
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"range":       rng,
+		"school_days": len(days),
+		"threshold":   map[string]any{"rate": rate, "consecutive": consec},
+		"items":       out,
+	})
+}
@@ -0,0 +1,464 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/*
+	รายการสถานะการเข้าเรียน (attendance_statuses)
+
+	  - attendances.status เก็บชื่อไทย (label_th) เหมือนเดิม + status_code เก็บรหัสคงที่
+	  - สถานะตั้งต้นของระบบ (system) ลบ/เปลี่ยนชื่อไทย/หมวดไม่ได้ — โค้ดส่วนอื่นอ้างชื่อเหล่านี้อยู่
+	  - admin เพิ่มสถานะเองได้ เช่น "กิจกรรม" (หมวด leave แต่นับว่ามาเรียน)
+	  - ตอนเริ่มระบบ: จับคู่แถวเก่าที่ยังไม่มี status_code (ตัดช่องว่าง/ชื่ออังกฤษ/คำพ้อง)
+	    แถวที่จับคู่ไม่ได้ดูได้ที่ /attendance-statuses/unmapped แล้วสั่ง remap
+*/
+
+const (
+	statusCategoryPresent = "present"
+	statusCategoryAbsent  = "absent"
+	statusCategoryLeave   = "leave"
+	statusCategoryPending = "pending" // ยังไม่มีข้อมูล (ไม่นับในสถิติ)
+
+	statusCodeIn         = "in"
+	statusCodeLate       = "late"
+	statusCodeOut        = "out"
+	statusCodeAbsent     = "absent"
+	statusCodeLeave      = "leave"
+	statusCodeNotArrived = "not_arrived"
+)
+
+var statusCategories = map[string]bool{
+	statusCategoryPresent: true, statusCategoryAbsent: true, statusCategoryLeave: true, statusCategoryPending: true,
+}
+
+var defaultAttendanceStatuses = []models.AttendanceStatus{
+	{Code: statusCodeIn, LabelTH: "เข้า", LabelEN: "Arrived", Category: statusCategoryPresent, CountsAsPresent: true, SortOrder: 10, Active: true, System: true},
+	{Code: statusCodeLate, LabelTH: "มาสาย", LabelEN: "Late", Category: statusCategoryPresent, CountsAsPresent: true, SortOrder: 20, Active: true, System: true},
+	{Code: statusCodeOut, LabelTH: "ออก", LabelEN: "Left school", Category: statusCategoryPresent, CountsAsPresent: true, SortOrder: 30, Active: true, System: true},
+	{Code: statusCodeAbsent, LabelTH: "ขาด", LabelEN: "Absent", Category: statusCategoryAbsent, SortOrder: 40, Active: true, System: true},
+	{Code: statusCodeLeave, LabelTH: "ลา", LabelEN: "On leave", Category: statusCategoryLeave, SortOrder: 50, Active: true, System: true},
+	{Code: statusCodeNotArrived, LabelTH: "ยังไม่เข้าโรงเรียน", LabelEN: "Not arrived", Category: statusCategoryPending, SortOrder: 60, Active: true, System: true},
+}
+
+// คำพ้องที่รับจาก FE/ข้อมูลเก่า → สถานะจริง + note ตั้งต้น ("ลากิจ/ลาป่วย" เก็บเป็น "ลา" + note)
+var attendanceStatusAliases = map[string]struct{ Code, Note string }{
+	"ลากิจ":  {statusCodeLeave, "ธุระส่วนตัว"},
+	"ลาป่วย": {statusCodeLeave, "ป่วย"},
+	"สาย":    {statusCodeLate, ""},
+}
+
+var reStatusCode = regexp.MustCompile(`^[a-z][a-z0-9_]{1,29}$`)
+
+/* ====================== catalogue (cache) ====================== */
+
+// cache ในหน่วยความจำ — โหลดใหม่เมื่อแก้ไข หรือทุก 1 นาที (กรณีหลาย instance)
+var statusCatalogue struct {
+	sync.RWMutex
+	rows     []models.AttendanceStatus
+	loadedAt time.Time
+}
+
+const statusCatalogueTTL = time.Minute
+
+func attendanceStatusList() []models.AttendanceStatus {
+	statusCatalogue.RLock()
+	rows, fresh := statusCatalogue.rows, time.Since(statusCatalogue.loadedAt) < statusCatalogueTTL
+	statusCatalogue.RUnlock()
+	if fresh && rows != nil {
+		return rows
+	}
+
+	var loaded []models.AttendanceStatus
+	if err := database.DB.Order("sort_order, id").Find(&loaded).Error; err != nil || len(loaded) == 0 {
+		if rows != nil {
+			return rows
+		}
+		return defaultAttendanceStatuses // DB ยังไม่พร้อม → ใช้ค่าตั้งต้น
+	}
+	statusCatalogue.Lock()
+	statusCatalogue.rows, statusCatalogue.loadedAt = loaded, time.Now()
+	statusCatalogue.Unlock()
+	return loaded
+}
+
+func invalidateAttendanceStatuses() {
+	statusCatalogue.Lock()
+	statusCatalogue.loadedAt = time.Time{}
+	statusCatalogue.Unlock()
+}
+
+// หา status จากชื่อไทย / รหัส / ชื่ออังกฤษ (รวมสถานะที่ปิดใช้งานแล้ว)
+func findAttendanceStatus(s string) (models.AttendanceStatus, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return models.AttendanceStatus{}, false
+	}
+	lower := strings.ToLower(s)
+	for _, st := range attendanceStatusList() {
+		if st.LabelTH == s || st.Code == lower || (st.LabelEN != "" && strings.ToLower(st.LabelEN) == lower) {
+			return st, true
+		}
+	}
+	return models.AttendanceStatus{}, false
+}
+
+// ชื่อไทยของรหัส (ไม่พบ = คืนรหัสเดิม)
+func attendanceStatusLabel(code string) string {
+	if st, ok := findAttendanceStatus(code); ok {
+		return st.LabelTH
+	}
+	return code
+}
+
+// รหัสของชื่อไทยที่เก็บใน attendances.status ("" = ไม่อยู่ในรายการ)
+func attendanceStatusCode(label string) string {
+	if st, ok := findAttendanceStatus(label); ok {
+		return st.Code
+	}
+	return ""
+}
+
+// ตรวจสถานะที่รับเข้ามากับ catalogue → ชื่อไทยที่ใช้เก็บ + note (คำพ้องเติม note ตั้งต้นให้)
+func normalizeAttendanceStatus(status, note string) (string, string, bool) {
+	status = strings.TrimSpace(status)
+	note = strings.TrimSpace(note)
+	if a, ok := attendanceStatusAliases[status]; ok {
+		status = a.Code
+		if note == "" {
+			note = a.Note
+		}
+	}
+	st, ok := findAttendanceStatus(status)
+	if !ok || !st.Active {
+		return "", "", false
+	}
+	return st.LabelTH, note, true
+}
+
+// สถานะที่ไม่มีเวลา (แสดง "—") = ทุกหมวดที่ไม่ใช่ "มาเรียน"
+func statusHasTime(status string) bool {
+	st, ok := findAttendanceStatus(status)
+	return !ok || st.Category == statusCategoryPresent
+}
+
+/* ====================== bootstrap + migration ====================== */
+
+// EnsureAttendanceStatuses สร้างสถานะตั้งต้น + จับคู่แถว attendances เก่ากับรหัส (เรียกตอนเริ่มระบบ)
+func EnsureAttendanceStatuses() error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, d := range defaultAttendanceStatuses {
+			var cur models.AttendanceStatus
+			err := tx.Where("code = ?", d.Code).First(&cur).Error
+			if err == nil {
+				continue
+			}
+			if err != gorm.ErrRecordNotFound {
+				return err
+			}
+			if err := tx.Create(&d).Error; err != nil {
+				return err
+			}
+			log.Printf("[bootstrap] attendance status created: %s (%s)", d.Code, d.LabelTH)
+		}
+
+		// คำพ้อง → สถานะจริง (note ว่างเติมให้)
+		for alias, a := range attendanceStatusAliases {
+			if err := tx.Exec(`UPDATE attendances a SET status = s.label_th, status_code = s.code,
+					note = CASE WHEN COALESCE(a.note, '') = '' THEN ? ELSE a.note END
+				FROM attendance_statuses s
+				WHERE s.code = ? AND a.status_code = '' AND TRIM(a.status) = ?`, a.Note, a.Code, alias).Error; err != nil {
+				return err
+			}
+		}
+		// ชื่อไทย (ตัดช่องว่าง) / รหัส / ชื่ออังกฤษ
+		res := tx.Exec(`UPDATE attendances a SET status = s.label_th, status_code = s.code
+			FROM attendance_statuses s
+			WHERE a.status_code = ''
+			  AND (TRIM(a.status) = s.label_th OR LOWER(TRIM(a.status)) = s.code OR LOWER(TRIM(a.status)) = LOWER(s.label_en))`)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			log.Printf("[migrate] mapped attendances.status_code (%d rows)", res.RowsAffected)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	invalidateAttendanceStatuses()
+
+	var unmapped int64
+	if err := database.DB.Model(&models.Attendance{}).Where("status_code = ''").Count(&unmapped).Error; err == nil && unmapped > 0 {
+		log.Printf("[migrate] warn: %d attendances have an unknown status (see GET /attendance-statuses/unmapped)", unmapped)
+	}
+	return nil
+}
+
+/* ====================== Handlers ====================== */
+
+type AttendanceStatusHandler struct{}
+
+func NewAttendanceStatusHandler() *AttendanceStatusHandler { return &AttendanceStatusHandler{} }
+
+type attendanceStatusReq struct {
+	Code            string `json:"code"`
+	LabelTH         string `json:"label_th"`
+	LabelEN         string `json:"label_en"`
+	Category        string `json:"category"`
+	CountsAsPresent *bool  `json:"counts_as_present"`
+	SortOrder       *int   `json:"sort_order"`
+	Active          *bool  `json:"active"`
+}
+
+func (h *AttendanceStatusHandler) find(c echo.Context) (*models.AttendanceStatus, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return nil, c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	var st models.AttendanceStatus
+	if err := database.DB.First(&st, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+		}
+		return nil, c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	return &st, nil
+}
+
+// ชื่อไทย/รหัส/ชื่ออังกฤษ ต้องไม่ชนกับสถานะอื่นและคำพ้อง (ไม่งั้นจับคู่กำกวม)
+func statusNameTaken(name string, exceptID uint) bool {
+	if _, ok := attendanceStatusAliases[name]; ok {
+		return true
+	}
+	var n int64
+	database.DB.Model(&models.AttendanceStatus{}).
+		Where("id <> ? AND (label_th = ? OR code = LOWER(?) OR LOWER(label_en) = LOWER(?))", exceptID, name, name, name).
+		Count(&n)
+	return n > 0
+}
+
+// GET /attendance-statuses?active=1
+func (h *AttendanceStatusHandler) List(c echo.Context) error {
+	tx := database.DB.Model(&models.AttendanceStatus{})
+	if c.QueryParam("active") == "1" {
+		tx = tx.Where("active = ?", true)
+	}
+	var rows []models.AttendanceStatus
+	if err := tx.Order("sort_order, id").Find(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, rows)
+}
+
+// POST /attendance-statuses
+// body: { code, label_th, label_en, category, counts_as_present, sort_order }
+func (h *AttendanceStatusHandler) Create(c echo.Context) error {
+	var req attendanceStatusReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	st := models.AttendanceStatus{
+		Code:     strings.ToLower(strings.TrimSpace(req.Code)),
+		LabelTH:  strings.TrimSpace(req.LabelTH),
+		LabelEN:  strings.TrimSpace(req.LabelEN),
+		Category: strings.TrimSpace(req.Category),
+		Active:   true,
+	}
+	if req.CountsAsPresent != nil {
+		st.CountsAsPresent = *req.CountsAsPresent
+	} else {
+		st.CountsAsPresent = st.Category == statusCategoryPresent
+	}
+	if req.SortOrder != nil {
+		st.SortOrder = *req.SortOrder
+	}
+
+	fields := map[string]string{}
+	if !reStatusCode.MatchString(st.Code) {
+		fields["code"] = "invalid"
+	} else if statusNameTaken(st.Code, 0) {
+		fields["code"] = "taken"
+	}
+	if st.LabelTH == "" || utf8.RuneCountInString(st.LabelTH) > 20 {
+		fields["label_th"] = "required (max 20)"
+	} else if statusNameTaken(st.LabelTH, 0) {
+		fields["label_th"] = "taken"
+	}
+	if st.LabelEN != "" && statusNameTaken(st.LabelEN, 0) {
+		fields["label_en"] = "taken"
+	}
+	if !statusCategories[st.Category] {
+		fields["category"] = "invalid"
+	}
+	if len(fields) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "VALIDATION_ERROR", "fields": fields})
+	}
+
+	if err := database.DB.Create(&st).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	invalidateAttendanceStatuses()
+	return c.JSON(http.StatusCreated, st)
+}
+
+// PUT /attendance-statuses/:id
+// สถานะของระบบแก้ได้เฉพาะ label_en / sort_order; รหัสแก้ไม่ได้เสมอ
+// เปลี่ยนชื่อไทย → อัปเดต attendances.status ของรหัสนี้ตาม
+func (h *AttendanceStatusHandler) Update(c echo.Context) error {
+	st, err := h.find(c)
+	if st == nil {
+		return err
+	}
+	var req attendanceStatusReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	req.Code = strings.ToLower(strings.TrimSpace(req.Code))
+	req.LabelTH = strings.TrimSpace(req.LabelTH)
+	req.LabelEN = strings.TrimSpace(req.LabelEN)
+	req.Category = strings.TrimSpace(req.Category)
+
+	if req.Code != "" && req.Code != st.Code {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "STATUS_CODE_IMMUTABLE"})
+	}
+	if st.System && ((req.LabelTH != "" && req.LabelTH != st.LabelTH) ||
+		(req.Category != "" && req.Category != st.Category) ||
+		(req.CountsAsPresent != nil && *req.CountsAsPresent != st.CountsAsPresent) ||
+		(req.Active != nil && !*req.Active)) {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "STATUS_IMMUTABLE"})
+	}
+
+	fields := map[string]string{}
+	oldLabel := st.LabelTH
+	if req.LabelTH != "" && req.LabelTH != st.LabelTH {
+		if utf8.RuneCountInString(req.LabelTH) > 20 {
+			fields["label_th"] = "max 20"
+		} else if statusNameTaken(req.LabelTH, st.ID) {
+			fields["label_th"] = "taken"
+		}
+		st.LabelTH = req.LabelTH
+	}
+	if req.LabelEN != st.LabelEN {
+		if req.LabelEN != "" && statusNameTaken(req.LabelEN, st.ID) {
+			fields["label_en"] = "taken"
+		}
+		st.LabelEN = req.LabelEN
+	}
+	if req.Category != "" {
+		if !statusCategories[req.Category] {
+			fields["category"] = "invalid"
+		}
+		st.Category = req.Category
+	}
+	if len(fields) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "VALIDATION_ERROR", "fields": fields})
+	}
+	if req.CountsAsPresent != nil {
+		st.CountsAsPresent = *req.CountsAsPresent
+	}
+	if req.SortOrder != nil {
+		st.SortOrder = *req.SortOrder
+	}
+	if req.Active != nil {
+		st.Active = *req.Active
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(st).Updates(map[string]any{
+			"label_th": st.LabelTH, "label_en": st.LabelEN, "category": st.Category,
+			"counts_as_present": st.CountsAsPresent, "sort_order": st.SortOrder, "active": st.Active,
+		}).Error; err != nil {
+			return err
+		}
+		if st.LabelTH == oldLabel {
+			return nil
+		}
+		return tx.Model(&models.Attendance{}).Where("status_code = ?", st.Code).Update("status", st.LabelTH).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	invalidateAttendanceStatuses()
+	return c.JSON(http.StatusOK, st)
+}
+
+// DELETE /attendance-statuses/:id — ลบได้เฉพาะสถานะที่ยังไม่มีข้อมูลใช้ (มีแล้วให้ปิดใช้งานแทน)
+func (h *AttendanceStatusHandler) Delete(c echo.Context) error {
+	st, err := h.find(c)
+	if st == nil {
+		return err
+	}
+	if st.System {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "STATUS_IMMUTABLE"})
+	}
+	var n int64
+	if err := database.DB.Model(&models.Attendance{}).Where("status_code = ?", st.Code).Count(&n).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	if n > 0 {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "STATUS_IN_USE", "attendance_count": n})
+	}
+	if err := database.DB.Delete(st).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_DELETE_ERROR"})
+	}
+	invalidateAttendanceStatuses()
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GET /attendance-statuses/unmapped — ค่า status ในข้อมูลเก่าที่จับคู่กับรายการไม่ได้
+func (h *AttendanceStatusHandler) Unmapped(c echo.Context) error {
+	var rows []struct {
+		Status string `json:"status"`
+		Count  int64  `json:"count"`
+	}
+	if err := database.DB.Model(&models.Attendance{}).
+		Select("status, COUNT(*) AS count").
+		Where("status_code = ''").
+		Group("status").
+		Order("count DESC").
+		Scan(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, rows)
+}
+
+// POST /attendance-statuses/remap
+// body: { from: "<ค่า status เดิม>", code: "<รหัสปลายทาง>" }
+func (h *AttendanceStatusHandler) Remap(c echo.Context) error {
+	var req struct {
+		From string `json:"from"`
+		Code string `json:"code"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	if req.From == "" {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "MISSING_FIELDS"})
+	}
+	var st models.AttendanceStatus
+	if err := database.DB.First(&st, "code = ?", strings.ToLower(strings.TrimSpace(req.Code))).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "STATUS_NOT_FOUND"})
+	}
+	res := database.DB.Model(&models.Attendance{}).
+		Where("status_code = '' AND status = ?", req.From).
+		Updates(map[string]any{"status": st.LabelTH, "status_code": st.Code})
+	if res.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"updated": res.RowsAffected, "status": st})
+}
@@ -13,33 +13,79 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/mailer"
 	"github.com/patiponrmutl/BESystem/models"
 )
 
 /* ====================== Config & Helpers ====================== */
 
 type AuthHandler struct {
-	JWTSecret string
+	Keys           *keyRing      // กุญแจเซ็น/ตรวจ JWT (RS256/EdDSA, หลาย kid)
+	AccessTTL      time.Duration // อายุ access token (สั้น)
+	RefreshTTL     time.Duration // อายุ refresh token (ยาว, หมุนทุกครั้งที่ใช้)
+	Login          loginPolicy   // กันเดารหัสผ่าน (หน่วงเวลา/ล็อกชั่วคราว)
+	ParentThrottle loginPolicy   // เหมือน Login แต่ตัวนับของผู้ปกครองแยกจาก staff
+	Reset          loginPolicy   // จำกัดคำขอลืมรหัสผ่าน
+	ResetTTL       time.Duration // อายุลิงก์รีเซ็ตรหัสผ่าน
+	Mailer         mailer.Mailer
 }
 
+var (
+	errRefreshRaced         = errors.New("refresh token already rotated")
+	errResetTokenUsed       = errors.New("reset token already used")
+	errResetAccountDisabled = errors.New("account disabled")
+	errLastAdmin            = errors.New("last admin")
+)
+
+// scope ของ token ที่ออกให้บัญชีที่ต้องเปลี่ยนรหัสผ่านก่อนใช้งาน
+const scopePasswordChange = "password_change"
+
+// role ของ token ผู้ปกครอง (sub = parents.id ไม่ใช่ users.id)
+const roleParent = "parent"
+
 func NewAuthHandler() *AuthHandler {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "dev-secret"
+	accessMin := atoiOr(os.Getenv("JWT_ACCESS_TTL_MINUTES"), 15)
+	refreshHours := atoiOr(os.Getenv("JWT_REFRESH_TTL_HOURS"), 24*30)
+	return &AuthHandler{
+		Keys:           loadKeyRingFromEnv(),
+		AccessTTL:      time.Duration(accessMin) * time.Minute,
+		RefreshTTL:     time.Duration(refreshHours) * time.Hour,
+		Login:          loadLoginPolicy(),
+		ParentThrottle: loadParentLoginPolicy(),
+		Reset:          loadResetPolicy(),
+		ResetTTL:       time.Duration(atoiOr(os.Getenv("PASSWORD_RESET_TTL_MINUTES"), 30)) * time.Minute,
+		Mailer:         mailer.FromEnv(),
 	}
-	return &AuthHandler{JWTSecret: secret}
 }
 
-func (h *AuthHandler) signJWT(sub uint, role, name string, ttl time.Duration) (string, error) {
+// sid = session id (FamilyID ของ refresh token) ใช้ตรวจว่า session ถูก logout/revoke ไปแล้วหรือยัง
+// scope ว่าง = token ปกติ, scopePasswordChange = ใช้ได้เฉพาะเส้นเปลี่ยนรหัสผ่าน
+func (h *AuthHandler) signJWT(sub uint, role, name, sid, scope string, ttl time.Duration) (string, error) {
 	claims := jwt.MapClaims{
 		"sub":  sub,
 		"role": role,
 		"name": name,
+		"sid":  sid,
 		"exp":  time.Now().Add(ttl).Unix(),
 		"iat":  time.Now().Unix(),
 	}
-	tk := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return tk.SignedString([]byte(h.JWTSecret))
+	if scope != "" {
+		claims["scope"] = scope
+	}
+	return h.Keys.Sign(claims)
+}
+
+// ตรวจลายเซ็น/วันหมดอายุของ token แล้วคืน claims
+func (h *AuthHandler) parseToken(tokenStr string) (jwt.MapClaims, error) {
+	tk, err := jwt.Parse(tokenStr, h.Keys.Keyfunc, jwt.WithValidMethods([]string{"RS256", "EdDSA"}))
+	if err != nil || !tk.Valid {
+		return nil, errors.New("invalid token")
+	}
+	claims, ok := tk.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid claims")
+	}
+	return claims, nil
 }
 
 // แปลง interface{} เป็น string แบบปลอดภัย
@@ -57,6 +103,30 @@ func asString(v any) string {
 	}
 }
 
+// แปลงค่า claim ตัวเลข (JSON decode เป็น float64) → uint
+func claimUint(v any) (uint, bool) {
+	switch t := v.(type) {
+	case float64:
+		if t <= 0 {
+			return 0, false
+		}
+		return uint(t), true
+	case int:
+		if t <= 0 {
+			return 0, false
+		}
+		return uint(t), true
+	case string:
+		n := atoiOr(t, 0)
+		if n <= 0 {
+			return 0, false
+		}
+		return uint(n), true
+	default:
+		return 0, false
+	}
+}
+
 /* ====================== DTOs ====================== */
 
 type StaffLoginReq struct {
@@ -68,7 +138,7 @@ type StaffLoginReq struct {
 
 func EnsureDefaultAdmin() error {
 	var cnt int64
-	if err := database.DB.Model(&models.User{}).Where("role = ?", "admin").Count(&cnt).Error; err != nil {
+	if err := database.DB.Model(&models.User{}).Where("role = ?", roleAdmin).Count(&cnt).Error; err != nil {
 		return err
 	}
 	if cnt > 0 {
@@ -80,6 +150,8 @@ func EnsureDefaultAdmin() error {
 		username = "Admin"
 	}
 	password := os.Getenv("ADMIN_SEED_PASSWORD")
+	// ใช้รหัสเริ่มต้น → บังคับเปลี่ยนรหัสตอน login ครั้งแรก
+	forceChange := password == ""
 	if password == "" {
 		password = "1234"
 	}
@@ -88,7 +160,7 @@ func EnsureDefaultAdmin() error {
 	if err != nil {
 		return err
 	}
-	u := models.User{Username: username, PasswordHash: string(hash), Role: "admin"}
+	u := models.User{Username: username, PasswordHash: string(hash), Role: roleAdmin, ForcePasswordChange: forceChange}
 	if err := database.DB.Create(&u).Error; err != nil {
 		return err
 	}
@@ -108,74 +180,208 @@ func (h *AuthHandler) StaffLogin(c echo.Context) error {
 	if username == "" || req.Password == "" {
 		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "MISSING_FIELDS"})
 	}
+	if err := h.Login.check(c, username); err != nil {
+		return err
+	}
 
 	var u models.User
 	if err := database.DB.Where("username = ?", username).First(&u).Error; err != nil {
+		h.Login.recordFailure(c, username, "INVALID_CREDENTIALS")
 		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_CREDENTIALS"})
 	}
 	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.Password)) != nil {
+		h.Login.recordFailure(c, username, "INVALID_CREDENTIALS")
 		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_CREDENTIALS"})
 	}
+	// เช็คหลังรหัสผ่านถูกแล้ว เพื่อไม่ให้ใช้เดาได้ว่าบัญชีไหนถูกปิด
+	if !u.Enabled {
+		return echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "ACCOUNT_DISABLED"})
+	}
 
-	token, err := h.signJWT(uint(u.ID), u.Role, u.Username, 8*time.Hour)
+	// เปิด 2FA ไว้ → ขั้นที่สองที่ /auth/login/2fa
+	// (ยังไม่ล้างตัวนับ login ผิด จนกว่าจะผ่านรหัส 6 หลัก กันเดารหัสแล้ว login ใหม่เพื่อล้างตัวนับ)
+	if u.TOTPEnabled {
+		mfa, err := h.signJWT(u.ID, u.Role, u.Username, "", scopeMFA, mfaChallengeTTL)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "TOKEN_GEN_FAILED"})
+		}
+		return c.JSON(http.StatusOK, map[string]any{
+			"mfa_required": true,
+			"mfa_token":    mfa,
+			"expires_in":   int(mfaChallengeTTL.Seconds()),
+		})
+	}
+	h.Login.recordSuccess(username)
+
+	resp, err := h.completeStaffLogin(c, &u)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "TOKEN_GEN_FAILED"})
+		return err
 	}
-	return c.JSON(http.StatusOK, map[string]any{
+	return c.JSON(http.StatusOK, resp)
+}
+
+// ขั้นสุดท้ายของ login staff (หลังผ่านรหัสผ่าน และ 2FA ถ้ามี)
+func (h *AuthHandler) completeStaffLogin(c echo.Context, u *models.User) (map[string]any, error) {
+	now := time.Now()
+	if err := database.DB.Model(u).UpdateColumn("last_login", &now).Error; err != nil {
+		log.Printf("[auth] warn: update last_login failed: %v", err)
+	}
+	u.LastLogin = &now
+
+	// บังคับเปลี่ยนรหัส → ออก token แบบจำกัดสิทธิ์ (เรียกได้เฉพาะเส้นเปลี่ยนรหัสผ่าน, ไม่มี refresh token)
+	if u.ForcePasswordChange {
+		return h.restrictedTokenResponse(u, scopePasswordChange, "password_change_required")
+	}
+	// role นี้ถูกบังคับ 2FA แต่ยังไม่ได้ตั้ง → ออก token ที่ใช้ได้เฉพาะเส้นตั้งค่า 2FA
+	if !u.TOTPEnabled && twoFactorRequired(u.Role) {
+		return h.restrictedTokenResponse(u, scopeMFAEnroll, "two_factor_enrollment_required")
+	}
+
+	resp, err := h.startSession(c, subjectForUser(u))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "TOKEN_GEN_FAILED"})
+	}
+	return resp, nil
+}
+
+// token แบบจำกัดสิทธิ์ (ไม่มี refresh token/session) + flag บอก frontend ว่าต้องทำอะไรต่อ
+func (h *AuthHandler) restrictedTokenResponse(u *models.User, scope, flag string) (map[string]any, error) {
+	token, err := h.signJWT(u.ID, u.Role, u.Username, "", scope, h.AccessTTL)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "TOKEN_GEN_FAILED"})
+	}
+	return map[string]any{
 		"access_token": token,
 		"token_type":   "Bearer",
+		"expires_in":   int(h.AccessTTL.Seconds()),
+		flag:           true,
 		"user": map[string]any{
 			"id":       u.ID,
 			"username": u.Username,
 			"role":     u.Role,
 		},
-	})
+	}, nil
 }
 
 // GET /auth/me
 func (h *AuthHandler) Me(c echo.Context) error {
-	claims, ok := c.Get("auth.claims").(jwt.MapClaims)
-	if !ok {
+	p := currentPrincipal(c)
+	if p == nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_TOKEN"})
 	}
+	id := p.UserID
+	if p.IsParent() {
+		id = p.ParentID
+	}
 	return c.JSON(http.StatusOK, map[string]any{
-		"id":       claims["sub"],
-		"username": claims["name"],
-		"role":     claims["role"],
+		"id":         id,
+		"username":   p.Name,
+		"role":       p.Role,
+		"teacher_id": p.TeacherID,
+		"parent_id":  p.ParentID,
+		"school_id":  p.SchoolID,
 	})
 }
 
 /* ====================== Middleware ====================== */
 
-// RequireAuth: parse JWT แล้วใส่ claims ลง context
+// RequireAuth: parse JWT, ตรวจ session/สถานะบัญชี แล้วใส่ Principal ลง context
+// token แบบจำกัดสิทธิ์ (ต้องเปลี่ยนรหัสผ่าน) ใช้กับเส้นนี้ไม่ได้
 func (h *AuthHandler) RequireAuth(next echo.HandlerFunc) echo.HandlerFunc {
-	secret := h.JWTSecret
+	return h.authenticate(next, "")
+}
+
+// RequirePasswordChangeAuth: เหมือน RequireAuth แต่ยอมรับ token แบบจำกัดสิทธิ์ด้วย
+// ใช้กับเส้นเปลี่ยนรหัสผ่านเท่านั้น
+func (h *AuthHandler) RequirePasswordChangeAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return h.authenticate(next, scopePasswordChange)
+}
+
+// RequireTwoFactorEnrollAuth: เหมือน RequireAuth แต่ยอมรับ token บังคับตั้ง 2FA ด้วย
+// ใช้กับเส้นตั้งค่า 2FA เท่านั้น
+func (h *AuthHandler) RequireTwoFactorEnrollAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return h.authenticate(next, scopeMFAEnroll)
+}
+
+// allowScope = scope ของ token จำกัดสิทธิ์ที่เส้นนี้ยอมรับเพิ่ม (ว่าง = รับเฉพาะ token ปกติ)
+func (h *AuthHandler) authenticate(next echo.HandlerFunc, allowScope string) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		ah := c.Request().Header.Get("Authorization")
 		if ah == "" || !strings.HasPrefix(ah, "Bearer ") {
 			return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "MISSING_AUTH_HEADER"})
 		}
-		tokenStr := strings.TrimPrefix(ah, "Bearer ")
-		tk, err := jwt.Parse(tokenStr, func(t *jwt.Token) (any, error) {
-			if t.Method != jwt.SigningMethodHS256 {
-				return nil, errors.New("invalid sign method")
-			}
-			return []byte(secret), nil
-		})
-		if err != nil || !tk.Valid {
+		claims, err := h.parseToken(strings.TrimPrefix(ah, "Bearer "))
+		if err != nil {
 			return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_TOKEN"})
 		}
-		claims, ok := tk.Claims.(jwt.MapClaims)
+
+		scope := asString(claims["scope"])
+		restricted := scope != ""
+		if restricted && scope != allowScope {
+			switch scope {
+			case scopePasswordChange:
+				return echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "PASSWORD_CHANGE_REQUIRED"})
+			case scopeMFAEnroll:
+				return echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "TWO_FACTOR_ENROLLMENT_REQUIRED"})
+			default:
+				// เช่น mfa_token ของขั้นที่สอง ใช้เรียก API ไม่ได้
+				return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_TOKEN"})
+			}
+		}
+		// logout/revoke แล้ว access token ที่ยังไม่หมดอายุต้องใช้ไม่ได้ทันที
+		// (token จำกัดสิทธิ์ไม่มี session แต่จะหมดผลทันทีที่ทำสิ่งที่ถูกบังคับเสร็จ — ดูเช็คข้างล่าง)
+		if !restricted && !sessionActive(asString(claims["sid"])) {
+			return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "TOKEN_REVOKED"})
+		}
+
+		uid, ok := claimUint(claims["sub"])
 		if !ok {
 			return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_TOKEN"})
 		}
-		c.Set("auth.claims", claims)
+		pr := &Principal{
+			SchoolID:  currentSchoolID(),
+			SessionID: asString(claims["sid"]),
+			Scope:     asString(claims["scope"]),
+		}
+
+		if asString(claims["role"]) == roleParent {
+			var p models.Parent
+			if err := database.DB.Select("id", "name").First(&p, uid).Error; err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "USER_NOT_FOUND"})
+			}
+			pr.ParentID, pr.Role, pr.Name = p.ID, roleParent, p.Name
+			c.Set(principalKey, pr)
+			return next(c)
+		}
+
+		// สถานะบัญชีเช็คทุก request: admin ปิดบัญชี/สั่งเปลี่ยนรหัสแล้วมีผลทันที
+		var u models.User
+		if err := database.DB.First(&u, uid).Error; err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "USER_NOT_FOUND"})
+		}
+		if !u.Enabled {
+			return echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "ACCOUNT_DISABLED"})
+		}
+		// token จำกัดสิทธิ์หมดผลทันทีเมื่อทำสิ่งที่ถูกบังคับเสร็จแล้ว
+		if (scope == scopePasswordChange && !u.ForcePasswordChange) || (scope == scopeMFAEnroll && u.TOTPEnabled) {
+			return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "TOKEN_REVOKED"})
+		}
+		if !restricted && u.ForcePasswordChange && allowScope != scopePasswordChange {
+			return echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "PASSWORD_CHANGE_REQUIRED"})
+		}
+
+		// role อ่านจาก DB (ถ้าแอดมินเปลี่ยน role ระหว่างที่ token ยังไม่หมดอายุ ให้มีผลทันที)
+		pr.UserID, pr.Role, pr.Name = u.ID, strings.ToLower(u.Role), u.Username
+		pr.TeacherID = teacherIDForUser(&u)
+		pr.Permissions = permissionsForRole(pr.Role)
+		c.Set(principalKey, pr)
 		return next(c)
 	}
 }
 
-// RequireRoles: ใช้ต่อท้ายจาก RequireAuth เพื่อบังคับสิทธิ์ตาม role
-// ตัวอย่าง: group.GET("/teachers", h.List, auth.RequireRoles("admin"))
+// RequireRoles: ใช้ต่อท้ายจาก RequireAuth เพื่อบังคับตามชื่อ role
+// ฝั่ง staff ให้ใช้ RequirePermission แทน (role แก้สิทธิ์ได้ใน DB) — ตอนนี้เหลือใช้กับ parent
+// ตัวอย่าง: group.GET("/children", h.List, auth.RequireRoles("parent"))
 func (h *AuthHandler) RequireRoles(roles ...string) echo.MiddlewareFunc {
 	// แปลง roles slice เป็น set
 	allowed := map[string]struct{}{}
@@ -185,12 +391,11 @@ func (h *AuthHandler) RequireRoles(roles ...string) echo.MiddlewareFunc {
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			claims, ok := c.Get("auth.claims").(jwt.MapClaims)
-			if !ok {
+			p := currentPrincipal(c)
+			if p == nil {
 				return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_TOKEN"})
 			}
-			role := strings.ToLower(asString(claims["role"]))
-			if _, ok := allowed[role]; !ok {
+			if _, ok := allowed[p.Role]; !ok {
 				return echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "FORBIDDEN"})
 			}
 			return next(c)
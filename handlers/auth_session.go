@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/* ====================== Token helpers ====================== */
+
+// สุ่ม token แบบ opaque (base64url, ไม่มี padding)
+func newOpaqueToken(nBytes int) (string, error) {
+	b := make([]byte, nBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// เก็บเฉพาะ sha256 ของ token ลง DB
+func hashToken(tok string) string {
+	sum := sha256.Sum256([]byte(tok))
+	return hex.EncodeToString(sum[:])
+}
+
+// session ยังใช้งานได้ไหม = ใน family นี้ยังมี refresh token ที่ไม่ถูก revoke และยังไม่หมดอายุ
+func sessionActive(sid string) bool {
+	if sid == "" {
+		return false
+	}
+	var n int64
+	if err := database.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL AND expires_at > ?", sid, time.Now()).
+		Count(&n).Error; err != nil {
+		return false
+	}
+	return n > 0
+}
+
+// revoke ทุก token ใน session เดียว
+func revokeSession(db *gorm.DB, sid string) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", sid).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revoke ทุก session ของผู้ใช้ (ออกจากระบบทุกเครื่อง)
+func revokeAllSessions(db *gorm.DB, userID uint) error {
+	return revokeOtherSessions(db, userID, "")
+}
+
+// revoke ทุก session ของผู้ใช้ ยกเว้น keepSid (เช่น เปลี่ยนรหัสแล้วให้เครื่องที่ใช้อยู่ไม่หลุด)
+func revokeOtherSessions(db *gorm.DB, userID uint, keepSid string) error {
+	q := db.Model(&models.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if keepSid != "" {
+		q = q.Where("family_id <> ?", keepSid)
+	}
+	return q.Update("revoked_at", time.Now()).Error
+}
+
+// revoke ทุก session ของผู้ปกครอง
+func revokeAllParentSessions(db *gorm.DB, parentID uint) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("parent_id = ? AND revoked_at IS NULL", parentID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// authSubject = เจ้าของ token (บัญชี users ของครู/แอดมิน หรือบัญชีผู้ปกครอง)
+type authSubject struct {
+	Sub      uint   // ใส่ใน claim "sub" (users.id หรือ parents.id ตาม role)
+	Role     string // admin | teacher | parent
+	Name     string
+	UserID   uint // เจ้าของ refresh token ฝั่ง users (0 ถ้าเป็นผู้ปกครอง)
+	ParentID uint // เจ้าของ refresh token ฝั่ง parents (0 ถ้าเป็น staff)
+	Profile  map[string]any
+}
+
+func subjectForUser(u *models.User) authSubject {
+	return authSubject{
+		Sub: u.ID, Role: u.Role, Name: u.Username, UserID: u.ID,
+		Profile: map[string]any{
+			"id":       u.ID,
+			"username": u.Username,
+			"role":     u.Role,
+		},
+	}
+}
+
+func subjectForParent(p *models.Parent) authSubject {
+	return authSubject{
+		Sub: p.ID, Role: roleParent, Name: p.Name, ParentID: p.ID,
+		Profile: map[string]any{
+			"id":    p.ID,
+			"name":  p.Name,
+			"email": p.Email,
+			"phone": p.Phone,
+			"role":  roleParent,
+		},
+	}
+}
+
+// ออก refresh token ใหม่ใน family ที่กำหนด (ใช้ทั้งตอน login และตอนหมุน)
+func (h *AuthHandler) createRefreshToken(tx *gorm.DB, c echo.Context, s authSubject, family string) (string, *models.RefreshToken, error) {
+	raw, err := newOpaqueToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	rt := models.RefreshToken{
+		UserID:    s.UserID,
+		ParentID:  s.ParentID,
+		FamilyID:  family,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(h.RefreshTTL),
+		UserAgent: truncate(c.Request().UserAgent(), 255),
+		IP:        c.RealIP(),
+	}
+	if err := tx.Create(&rt).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, &rt, nil
+}
+
+// ปั้น response token คู่ (access + refresh) ให้รูปแบบเดียวกันทุกเส้น
+func (h *AuthHandler) tokenPairResponse(s authSubject, sid, refresh string) (map[string]any, error) {
+	access, err := h.signJWT(s.Sub, s.Role, s.Name, sid, "", h.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"access_token":  access,
+		"refresh_token": refresh,
+		"token_type":    "Bearer",
+		"expires_in":    int(h.AccessTTL.Seconds()),
+		"user":          s.Profile,
+	}, nil
+}
+
+// เริ่ม session ใหม่หลัง login สำเร็จ
+func (h *AuthHandler) startSession(c echo.Context, s authSubject) (map[string]any, error) {
+	family, err := newOpaqueToken(16)
+	if err != nil {
+		return nil, err
+	}
+	refresh, _, err := h.createRefreshToken(database.DB, c, s, family)
+	if err != nil {
+		return nil, err
+	}
+	return h.tokenPairResponse(s, family, refresh)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+/* ====================== DTOs ====================== */
+
+type refreshReq struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type logoutReq struct {
+	All bool `json:"all"` // true = ออกจากระบบทุกเครื่อง
+}
+
+/* ====================== Handlers ====================== */
+
+// POST /auth/refresh
+// body: { refresh_token }
+// หมุน refresh token ทุกครั้ง: ตัวเก่าใช้ซ้ำไม่ได้ ถ้ามีคนเอาตัวเก่ามาใช้อีก → ถือว่าหลุด ตัด session ทั้ง family
+func (h *AuthHandler) Refresh(c echo.Context) error {
+	var req refreshReq
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	raw := strings.TrimSpace(req.RefreshToken)
+	if raw == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "MISSING_FIELDS"})
+	}
+
+	var cur models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", hashToken(raw)).First(&cur).Error; err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_REFRESH_TOKEN"})
+	}
+	if cur.RevokedAt != nil {
+		// reuse detection: token นี้ถูกหมุนหรือถูก logout ไปแล้ว
+		_ = revokeSession(database.DB, cur.FamilyID)
+		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "REFRESH_TOKEN_REVOKED"})
+	}
+	if time.Now().After(cur.ExpiresAt) {
+		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "REFRESH_TOKEN_EXPIRED"})
+	}
+
+	sub, err := refreshSubject(&cur)
+	if err != nil {
+		_ = revokeSession(database.DB, cur.FamilyID)
+		return err
+	}
+
+	var next string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// กันหมุนซ้อนกันสองคำขอ: update เฉพาะแถวที่ยังไม่ถูก revoke
+		now := time.Now()
+		res := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", cur.ID).
+			Update("revoked_at", &now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errRefreshRaced
+		}
+		raw, rt, err := h.createRefreshToken(tx, c, sub, cur.FamilyID)
+		if err != nil {
+			return err
+		}
+		next = raw
+		return tx.Model(&models.RefreshToken{}).Where("id = ?", cur.ID).Update("replaced_by", rt.ID).Error
+	})
+	if err == errRefreshRaced {
+		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "REFRESH_TOKEN_REVOKED"})
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "TOKEN_GEN_FAILED"})
+	}
+
+	resp, err := h.tokenPairResponse(sub, cur.FamilyID, next)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "TOKEN_GEN_FAILED"})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// POST /auth/logout
+// body: { all?: bool } — ค่าเริ่มต้นออกเฉพาะ session ปัจจุบัน, all=true ออกทุกเครื่อง
+func (h *AuthHandler) Logout(c echo.Context) error {
+	p := currentPrincipal(c)
+	if p == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_TOKEN"})
+	}
+	var req logoutReq
+	_ = c.Bind(&req) // body ว่างได้
+
+	if req.All {
+		var err error
+		if p.IsParent() {
+			err = revokeAllParentSessions(database.DB, p.ParentID)
+		} else {
+			err = revokeAllSessions(database.DB, p.UserID)
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+		}
+		return c.JSON(http.StatusOK, map[string]any{"ok": true, "scope": "all"})
+	}
+
+	if err := revokeSession(database.DB, p.SessionID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"ok": true, "scope": "session"})
+}
+
+// โหลดเจ้าของ refresh token แล้วเช็คว่ายังออก token ใหม่ให้ได้ไหม
+func refreshSubject(rt *models.RefreshToken) (authSubject, error) {
+	if rt.ParentID != 0 {
+		var p models.Parent
+		if err := database.DB.First(&p, rt.ParentID).Error; err != nil {
+			return authSubject{}, echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "USER_NOT_FOUND"})
+		}
+		return subjectForParent(&p), nil
+	}
+
+	var u models.User
+	if err := database.DB.First(&u, rt.UserID).Error; err != nil {
+		return authSubject{}, echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "USER_NOT_FOUND"})
+	}
+	if !u.Enabled {
+		return authSubject{}, echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "ACCOUNT_DISABLED"})
+	}
+	if u.ForcePasswordChange {
+		// ต้อง login ใหม่เพื่อรับ token แบบจำกัดสิทธิ์แล้วเปลี่ยนรหัสก่อน
+		return authSubject{}, echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "PASSWORD_CHANGE_REQUIRED"})
+	}
+	if !u.TOTPEnabled && twoFactorRequired(u.Role) {
+		// แอดมินเพิ่งเปิดบังคับ 2FA ให้ role นี้ → login ใหม่แล้วตั้ง 2FA ก่อน
+		return authSubject{}, echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "TWO_FACTOR_ENROLLMENT_REQUIRED"})
+	}
+	return subjectForUser(&u), nil
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+func testAuthHandler(t *testing.T) *AuthHandler {
+	t.Helper()
+	keys, err := newEphemeralKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &AuthHandler{Keys: keys, AccessTTL: time.Minute, RefreshTTL: time.Hour}
+}
+
+func testEchoContext(method, path, body string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	return echo.New().NewContext(req, rec), rec
+}
+
+// เรียก /auth/refresh แล้วคืน (status, error code, refresh token ใหม่)
+func doRefresh(t *testing.T, h *AuthHandler, token string) (int, string, string) {
+	t.Helper()
+	c, rec := testEchoContext(http.MethodPost, "/auth/refresh", `{"refresh_token":"`+token+`"}`)
+	if err := h.Refresh(c); err != nil {
+		he, ok := err.(*echo.HTTPError)
+		if !ok {
+			t.Fatalf("Refresh: %v", err)
+		}
+		msg, _ := he.Message.(map[string]any)
+		code, _ := msg["error"].(string)
+		return he.Code, code, ""
+	}
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	next, _ := out["refresh_token"].(string)
+	return rec.Code, "", next
+}
+
+func TestRefreshRotationAndReuse(t *testing.T) {
+	useTestDB(t, &models.User{}, &models.RefreshToken{}, &models.TwoFactorPolicy{})
+	h := testAuthHandler(t)
+
+	u := models.User{Username: "refresh-test", PasswordHash: "x", Role: "teacher", Enabled: true}
+	if err := database.DB.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	login := func() (string, string) {
+		c, _ := testEchoContext(http.MethodPost, "/auth/login", "")
+		resp, err := h.startSession(c, subjectForUser(&u))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rt models.RefreshToken
+		database.DB.Where("token_hash = ?", hashToken(resp["refresh_token"].(string))).First(&rt)
+		return resp["refresh_token"].(string), rt.FamilyID
+	}
+
+	first, family := login()
+	_, otherFamily := login() // อีกเครื่องหนึ่งของผู้ใช้เดียวกัน
+	var second, third string
+
+	steps := []struct {
+		name     string
+		token    func() string
+		wantCode int
+		wantErr  string
+		save     *string
+	}{
+		{"rotate first", func() string { return first }, http.StatusOK, "", &second},
+		{"rotate second", func() string { return second }, http.StatusOK, "", &third},
+		{"reuse rotated first", func() string { return first }, http.StatusUnauthorized, "REFRESH_TOKEN_REVOKED", nil},
+		{"newest token revoked with family", func() string { return third }, http.StatusUnauthorized, "REFRESH_TOKEN_REVOKED", nil},
+		{"unknown token", func() string { return "not-a-token" }, http.StatusUnauthorized, "INVALID_REFRESH_TOKEN", nil},
+	}
+	for _, s := range steps {
+		code, errCode, next := doRefresh(t, h, s.token())
+		if code != s.wantCode || errCode != s.wantErr {
+			t.Fatalf("%s: got %d %q, want %d %q", s.name, code, errCode, s.wantCode, s.wantErr)
+		}
+		if s.save != nil {
+			if next == "" {
+				t.Fatalf("%s: no refresh token in response", s.name)
+			}
+			*s.save = next
+		}
+	}
+
+	var active int64
+	database.DB.Model(&models.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", family).Count(&active)
+	if active != 0 || sessionActive(family) {
+		t.Errorf("reused family still has %d active token(s)", active)
+	}
+	if !sessionActive(otherFamily) {
+		t.Errorf("reuse in one session revoked another session")
+	}
+}
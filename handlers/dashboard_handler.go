@@ -18,7 +18,8 @@ func NewDashboardHandler() *DashboardHandler { return &DashboardHandler{} }
 
 // GET /teacher/dashboard/daily?date=YYYY-MM-DD&classroom=1/1
 // คืนรูปแบบที่ FE ใช้ในหน้า Dashboard:
-// { holiday: { isHoliday: bool, name: string }, rows: [{ id, student_id, status, time, note, operator, retro }] }
+// { holiday: { isHoliday, name, reason }, rows: [...ทุกคนในห้อง], counts: { <status>: n }, total }
+// นักเรียนที่ยังไม่มีข้อมูลของวันนั้น → status "ยังไม่เข้าโรงเรียน"
 func (h *DashboardHandler) Daily(c echo.Context) error {
 	date := strings.TrimSpace(c.QueryParam("date"))
 	classroom := strings.TrimSpace(c.QueryParam("classroom")) // "ชั้น/ห้อง" เช่น "1/1" (อาจว่าง)
@@ -27,114 +28,101 @@ func (h *DashboardHandler) Daily(c echo.Context) error {
 		// default: วันนี้ (เขตเวลาของเครื่องรัน)
 		date = time.Now().Format("2006-01-02")
 	}
-
-	// 1) ตรวจวันหยุด (optional ถ้าคุณมีตารางวันหยุด)
-	holiday := map[string]any{"isHoliday": false, "name": ""}
-	type holidayRow struct {
-		Name     string
-		DateFrom string // YYYY-MM-DD
-		DateTo   string // YYYY-MM-DD
-	}
-	var hRows []holidayRow
-	// ตัวอย่าง: ถ้าคุณเก็บวันหยุดในตาราง calendar_holidays (แก้ชื่อตาราง/ฟิลด์ให้ตรงโปรเจกต์จริง)
-	_ = database.DB.
-		Table("calendar_holidays").
-		Select("name, date_from, date_to").
-		Where("? BETWEEN date_from AND date_to", date).
-		Scan(&hRows)
-	if len(hRows) > 0 {
-		holiday["isHoliday"] = true
-		holiday["name"] = strings.TrimSpace(hRows[0].Name)
-	}
-
-	// 2) โหลด attendance ของวันนั้น (อาจกรองตาม classroom)
-	// ถ้าส่ง classroom มา → join students เพื่อกรอง grade/room
-	type row struct {
-		ID        any    `json:"id"`
-		StudentID uint   `json:"student_id"`
-		Status    string `json:"status"`
-		Time      string `json:"time"`
-		Note      string `json:"note"`
-		Operator  string `json:"operator"`
-		Retro     bool   `json:"retro"`
-		StudentNm string `json:"student_name"` // FE เผื่อใช้
+	if !isDateYYYYMMDD(date) {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_DATE"})
 	}
 
-	tx := database.DB.Table("attendances AS a").
-		Select("a.id, a.student_id, a.status, COALESCE(a.time,'—') AS time, COALESCE(a.note,'') AS note, '' AS operator, false AS retro")
-
-	if classroom != "" {
-		// classroom = "<grade>/<room>" → ดึงเฉพาะห้องนี้
-		var grade, room string
-		if m := parseClassroom(classroom); m != nil {
-			grade, room = m[0], m[1]
-			tx = tx.Joins("JOIN students s ON s.id = a.student_id").
-				Where("s.grade = ? AND s.room = ?", grade, room)
+	// 1) วันหยุดตามปฏิทินการศึกษา (วันหยุดหลายวัน / เสาร์-อาทิตย์ / นอกภาคเรียน)
+	info := schoolDayOf(date)
+	holiday := map[string]any{"isHoliday": !info.SchoolDay, "name": "", "reason": info.Reason}
+	if !info.SchoolDay {
+		holiday["name"] = nonSchoolDayNotes[info.Reason]
+		if info.Reason == "holiday" {
+			holiday["name"] = info.Holiday
 		}
 	}
 
-	tx = tx.Where("a.date = ?", date)
-
-	var rows []row
-	if err := tx.Order("a.student_id ASC, a.time ASC, a.id ASC").Scan(&rows).Error; err != nil && err != gorm.ErrRecordNotFound {
-		return c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+	// 2) นักเรียนทุกคนในห้องที่ขอ (ภายในขอบเขตของผู้ใช้)
+	scope := scopeOf(currentPrincipal(c))
+	stx := scope.where(database.DB.Model(&models.Student{}), "grade", "room").
+		Where("status NOT IN ?", inactiveStudentStatuses)
+	if m := parseClassroom(classroom); m != nil {
+		stx = stx.Where("grade = ? AND room = ?", m[0], m[1])
+	}
+	var students []models.Student
+	if err := stx.Order("grade, room, student_id, id").Find(&students).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	ids := make([]uint, 0, len(students))
+	for _, s := range students {
+		ids = append(ids, s.ID)
 	}
 
-	// 3) เติมใบลาที่ "อนุมัติแล้ว" ให้กลายเป็นสถานะ "ลา" ของวันนี้
-	var leaves []struct {
-		ID        uint
-		StudentID uint
-		Type      string
-		DateFrom  string
-		DateTo    string
-		Status    string
+	type row struct {
+		ID           any        `json:"id"` // nil = ยังไม่มีข้อมูล
+		StudentID    uint       `json:"student_id"`
+		Status       string     `json:"status"`
+		StatusCode   string     `json:"status_code"`
+		Time         string     `json:"time"`
+		Note         string     `json:"note"`
+		Operator     string     `json:"operator"`    // username ของคนบันทึก ("" = ระบบ/ข้อมูลเก่า)
+		RecordedBy   *uint      `json:"recorded_by"` // users.id
+		RecordedAt   *time.Time `json:"recorded_at"` // เวลาที่กดบันทึกจริง
+		Retro        bool       `json:"retro"`
+		MinutesLate  int        `json:"minutes_late"`
+		EarlyLeave   bool       `json:"early_leave"`
+		MinutesEarly int        `json:"minutes_early"`
+		Source       string     `json:"source"`       // manual | gate_qr | gate_card | system (เติมขาดอัตโนมัติ) | leave (ใบลาที่อนุมัติ)
+		StudentNm    string     `json:"student_name"` // FE เผื่อใช้
+		StudentCode  string     `json:"student_code"`
+		Grade        string     `json:"grade"`
+		Room         string     `json:"room"`
 	}
-	_ = database.DB.Table("leave_requests").
-		Select("id, student_id, type, date_from, date_to, status").
-		Where("? BETWEEN date_from AND date_to", date).
-		Where("status = ?", "อนุมัติ").
-		Scan(&leaves)
-
-	// แปลง leave → แถว "ลา"
-	leaveMap := map[uint]row{}
-	for _, lv := range leaves {
-		note := ""
-		switch strings.TrimSpace(lv.Type) {
-		case "ลาป่วย", "ป่วย":
-			note = "ป่วย"
-		case "ลากิจ", "ธุระส่วนตัว":
-			note = "ธุระส่วนตัว"
-		default:
-			note = "" // อื่นๆ
-		}
-		leaveMap[lv.StudentID] = row{
-			ID:        "leave-" + date + "-" + itoa(lv.ID),
-			StudentID: lv.StudentID,
-			Status:    "ลา",
-			Time:      "—",
-			Note:      note,
-			Operator:  "",
-			Retro:     false,
+
+	// 3) attendance ของวันนั้น (แถวล่าสุดของแต่ละคน)
+	var rows []row
+	if len(ids) > 0 {
+		if err := database.DB.Table("attendances AS a").
+			Select("a.id, a.student_id, a.status, a.status_code, COALESCE(a.time,'—') AS time, COALESCE(a.note,'') AS note, "+
+				"COALESCE(u.username,'') AS operator, a.recorded_by, a.recorded_at, a.retro, "+
+				"a.minutes_late, a.early_leave, a.minutes_early, a.source").
+			Joins("LEFT JOIN users u ON u.id = a.recorded_by").
+			Where("a.date = ? AND a.voided_at IS NULL AND a.student_id IN ?", date, ids).
+			Order("a.student_id ASC, a.time ASC, a.id ASC").
+			Scan(&rows).Error; err != nil && err != gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
 		}
 	}
-
-	// รวม attendance + leave (เอา leave ทับสถานะอื่นของวันนั้น)
 	latestByStu := map[uint]row{}
 	for _, r := range rows {
 		latestByStu[r.StudentID] = r
 	}
-	for sid, lr := range leaveMap {
-		latestByStu[sid] = lr
-	}
 
-	out := make([]row, 0, len(latestByStu))
-	for _, r := range latestByStu {
+	// 4) ทุกคนในห้อง (คนที่ไม่มีข้อมูล = ยังไม่เข้าโรงเรียน) + นับตามสถานะ
+	counts := map[string]int{}
+	for _, st := range attendanceStatusList() {
+		if st.Active {
+			counts[st.LabelTH] = 0
+		}
+	}
+	notArrived := attendanceStatusLabel(statusCodeNotArrived)
+	out := make([]row, 0, len(students))
+	for _, s := range students {
+		r, ok := latestByStu[s.ID]
+		if !ok {
+			r = row{StudentID: s.ID, Status: notArrived, StatusCode: statusCodeNotArrived, Time: "—"}
+		}
+		r.StudentNm, r.StudentCode, r.Grade, r.Room = studentFullName(s), s.StudentID, s.Grade, s.Room
+		counts[r.Status]++
 		out = append(out, r)
 	}
 
 	return c.JSON(http.StatusOK, map[string]any{
+		"date":    date,
 		"holiday": holiday,
 		"rows":    out,
+		"counts":  counts,
+		"total":   len(out),
 	})
 }
 
@@ -173,10 +161,13 @@ func (h *DashboardHandler) Summary(c echo.Context) error {
 		cntLeaves   int64
 	)
 
-	database.DB.Model(&models.Student{}).Count(&cntStudents)
+	// นับเฉพาะในขอบเขตของผู้ใช้ (ครู = ห้องที่ประจำชั้น)
+	p := currentPrincipal(c)
+	scope := scopeOf(p)
+	scope.where(database.DB.Model(&models.Student{}), "grade", "room").Count(&cntStudents)
 	database.DB.Model(&models.Teacher{}).Count(&cntTeachers)
-	database.DB.Model(&models.Homeroom{}).Count(&cntRooms)
-	database.DB.Model(&models.LeaveRequest{}).Where("status = ?", "pending").Count(&cntLeaves)
+	scopeHomerooms(p, database.DB.Model(&models.Homeroom{})).Count(&cntRooms)
+	scope.students(database.DB.Model(&models.LeaveRequest{}), "student_id").Where("status = ?", "รออนุมัติ").Count(&cntLeaves)
 
 	return c.JSON(http.StatusOK, map[string]any{
 		"students":       cntStudents,
@@ -0,0 +1,543 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/*
+	เช็คชื่อหน้าประตูด้วย QR / บัตรนักเรียน
+
+	  - เครื่องสแกน (GateDevice) ยืนยันตัวด้วย header X-Device-Key: <prefix>.<secret>
+	    (admin สร้าง/หมุน key ได้ — key แสดงครั้งเดียวตอนสร้าง)
+	  - QR ของนักเรียน = "S<student_id>.<step>.<hmac>" เปลี่ยนทุก GATE_QR_PERIOD_SECONDS
+	    (รับ step ปัจจุบันและก่อนหน้า 1 step เผื่อหน้าจอยังไม่ refresh)
+	  - สแกนซ้ำภายใน GATE_DOUBLE_SCAN_MINUTES → ไม่บันทึกเพิ่ม ตอบแถวเดิม (duplicate: true)
+*/
+
+const (
+	gateDeviceKey    = "gate.device"
+	gateSourceQR     = "gate_qr"
+	gateSourceCard   = "gate_card"
+	gateDirectionIn  = "เข้า"
+	gateDirectionOut = "ออก"
+	gateDirectionAny = "auto"
+)
+
+var (
+	errQRInvalid       = errors.New("invalid qr token")
+	errQRExpired       = errors.New("qr token expired")
+	errStudentInactive = errors.New("student inactive")
+)
+
+type GateHandler struct {
+	Att        *AttendanceHandler // กฎสาย/ออกก่อนตามภาคเรียน
+	QRSecret   []byte
+	QRPeriod   time.Duration
+	DoubleScan time.Duration
+}
+
+func NewGateHandler(att *AttendanceHandler) *GateHandler {
+	secret := []byte(os.Getenv("GATE_QR_SECRET"))
+	if len(secret) < 32 {
+		env := os.Getenv("APP_ENV")
+		if env != "" && env != "dev" {
+			log.Fatal("[gate] GATE_QR_SECRET must be at least 32 characters")
+		}
+		log.Printf("[gate] warn: GATE_QR_SECRET not set — using an ephemeral secret (dev only)")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("[gate] cannot generate QR secret: %v", err)
+		}
+	}
+	period := atoiOr(os.Getenv("GATE_QR_PERIOD_SECONDS"), 60)
+	if period < 15 {
+		period = 15
+	}
+	return &GateHandler{
+		Att:        att,
+		QRSecret:   secret,
+		QRPeriod:   time.Duration(period) * time.Second,
+		DoubleScan: time.Duration(atoiOr(os.Getenv("GATE_DOUBLE_SCAN_MINUTES"), 3)) * time.Minute,
+	}
+}
+
+/* ====================== QR token ====================== */
+
+func (h *GateHandler) qrStep(t time.Time) int64 {
+	return t.Unix() / int64(h.QRPeriod/time.Second)
+}
+
+func (h *GateHandler) qrMAC(payload string) string {
+	m := hmac.New(sha256.New, h.QRSecret)
+	m.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil)[:16])
+}
+
+func (h *GateHandler) signQR(studentID uint, step int64) string {
+	payload := "S" + strconv.FormatUint(uint64(studentID), 10) + "." + strconv.FormatInt(step, 10)
+	return payload + "." + h.qrMAC(payload)
+}
+
+func (h *GateHandler) verifyQR(tok string, now time.Time) (uint, error) {
+	parts := strings.Split(strings.TrimSpace(tok), ".")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "S") {
+		return 0, errQRInvalid
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(h.qrMAC(payload)), []byte(parts[2])) {
+		return 0, errQRInvalid
+	}
+	sid, err := strconv.ParseUint(parts[0][1:], 10, 64)
+	if err != nil || sid == 0 {
+		return 0, errQRInvalid
+	}
+	step, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, errQRInvalid
+	}
+	if cur := h.qrStep(now); step != cur && step != cur-1 {
+		return 0, errQRExpired
+	}
+	return uint(sid), nil
+}
+
+// token ปัจจุบันของนักเรียน + เวลาหมดอายุ (FE ควรขอใหม่ก่อนหมด)
+func (h *GateHandler) qrResponse(studentID uint) map[string]any {
+	now := time.Now()
+	step := h.qrStep(now)
+	expires := time.Unix((step+1)*int64(h.QRPeriod/time.Second), 0)
+	return map[string]any{
+		"student_id":      studentID,
+		"token":           h.signQR(studentID, step),
+		"expires_at":      expires,
+		"refresh_seconds": int(h.QRPeriod / time.Second),
+	}
+}
+
+// GET /students/:id/qr — staff (เฉพาะนักเรียนในห้องที่ดูแล)
+func (h *GateHandler) StudentQR(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	if !scopeOf(currentPrincipal(c)).allowsStudent(uint(id)) {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+	}
+	return c.JSON(http.StatusOK, h.qrResponse(uint(id)))
+}
+
+// GET /parent/children/:id/qr — ผู้ปกครอง (เฉพาะลูกที่อนุมัติแล้ว)
+func (h *GateHandler) ParentChildQR(c echo.Context) error {
+	p := currentPrincipal(c)
+	if !p.IsParent() {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	if !parentHasChild(p.ParentID, uint(id)) {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+	}
+	return c.JSON(http.StatusOK, h.qrResponse(uint(id)))
+}
+
+/* ====================== Device auth ====================== */
+
+// "<prefix>.<secret>" — prefix ใช้หาแถว, เทียบ sha256 ของทั้งก้อน
+func newDeviceKey() (prefix, key string, err error) {
+	prefix, err = newOpaqueToken(6)
+	if err != nil {
+		return "", "", err
+	}
+	prefix = strings.NewReplacer("-", "x", "_", "y").Replace(prefix)
+	secret, err := newOpaqueToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return prefix, prefix + "." + secret, nil
+}
+
+// RequireDevice: ใช้กับ route ของเครื่องสแกน (ไม่ใช้ JWT)
+func (h *GateHandler) RequireDevice(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := strings.TrimSpace(c.Request().Header.Get("X-Device-Key"))
+		prefix, _, ok := strings.Cut(key, ".")
+		if !ok || prefix == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_DEVICE_KEY"})
+		}
+		var dev models.GateDevice
+		if err := database.DB.Where("key_prefix = ?", prefix).First(&dev).Error; err != nil ||
+			subtle.ConstantTimeCompare([]byte(hashToken(key)), []byte(dev.KeyHash)) != 1 {
+			return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_DEVICE_KEY"})
+		}
+		if !dev.Enabled {
+			return echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "DEVICE_DISABLED"})
+		}
+		now := time.Now()
+		_ = database.DB.Model(&dev).Updates(map[string]any{"last_seen_at": &now, "last_ip": c.RealIP()}).Error
+		c.Set(gateDeviceKey, &dev)
+		return next(c)
+	}
+}
+
+func currentDevice(c echo.Context) *models.GateDevice {
+	d, _ := c.Get(gateDeviceKey).(*models.GateDevice)
+	return d
+}
+
+/* ====================== Scan ====================== */
+
+type gateScanReq struct {
+	QR        string `json:"qr"`
+	CardUID   string `json:"card_uid"`
+	Direction string `json:"direction"` // ใช้ได้เมื่อเครื่องตั้งเป็น auto
+}
+
+// UID จากเครื่องอ่านแต่ละยี่ห้อมีรูปแบบต่างกัน (04:A2:..., 04a2...) → ตัวพิมพ์ใหญ่ ไม่มีตัวคั่น
+func normalizeCardUID(s string) string {
+	return strings.ToUpper(strings.NewReplacer(":", "", "-", "", " ", "").Replace(strings.TrimSpace(s)))
+}
+
+// POST /gate/scan
+// body: { qr } หรือ { card_uid } (+ direction ถ้าเครื่องเป็น auto)
+func (h *GateHandler) Scan(c echo.Context) error {
+	dev := currentDevice(c)
+	if dev == nil {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"error": "INVALID_DEVICE_KEY"})
+	}
+	var req gateScanReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	now := time.Now()
+
+	// 1) หานักเรียนจาก QR หรือบัตร
+	var (
+		studentID uint
+		source    string
+	)
+	switch {
+	case strings.TrimSpace(req.QR) != "":
+		sid, err := h.verifyQR(req.QR, now)
+		if err == errQRExpired {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "QR_EXPIRED"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "QR_INVALID"})
+		}
+		studentID, source = sid, gateSourceQR
+	case strings.TrimSpace(req.CardUID) != "":
+		var card models.StudentCard
+		if err := database.DB.Where("card_uid = ? AND active = ?", normalizeCardUID(req.CardUID), true).
+			First(&card).Error; err != nil {
+			return c.JSON(http.StatusNotFound, map[string]any{"error": "CARD_NOT_FOUND"})
+		}
+		studentID, source = card.StudentID, gateSourceCard
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "MISSING_FIELDS"})
+	}
+
+	dir := dev.Direction
+	if dir == gateDirectionAny {
+		if d := strings.TrimSpace(req.Direction); d == gateDirectionIn || d == gateDirectionOut {
+			dir = d
+		}
+	}
+
+	// 2) บันทึก (ล็อกแถวนักเรียน → สแกนพร้อมกัน 2 เครื่องไม่ได้ 2 แถว)
+	date := now.Format("2006-01-02")
+	var (
+		stu       models.Student
+		rec       models.Attendance
+		duplicate bool
+	)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&stu, studentID).Error; err != nil {
+			return err
+		}
+		// ลาออก/จบ/ย้ายออก → ไม่บันทึก (เหมือน dashboard/งานเติม "ขาด")
+		if slices.Contains(inactiveStudentStatuses, stu.Status) {
+			return errStudentInactive
+		}
+		// สแกนซ้ำในไม่กี่นาที → ใช้แถวเดิม
+		err := tx.Where("student_id = ? AND date = ? AND source IN ? AND recorded_at >= ? AND voided_at IS NULL",
+			studentID, date, []string{gateSourceQR, gateSourceCard}, now.Add(-h.DoubleScan)).
+			Order("recorded_at DESC").First(&rec).Error
+		if err == nil {
+			duplicate = true
+			return nil
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		if dir == gateDirectionAny {
+			// สแกนแรกของวัน = เข้า, หลังจากนั้น = ออก
+			var n int64
+			if err := tx.Model(&models.Attendance{}).
+				Where("student_id = ? AND date = ? AND status_code IN ? AND voided_at IS NULL", studentID, date, []string{statusCodeIn, statusCodeLate}).
+				Count(&n).Error; err != nil {
+				return err
+			}
+			dir = gateDirectionIn
+			if n > 0 {
+				dir = gateDirectionOut
+			}
+		}
+		devID := dev.ID
+		rec = models.Attendance{
+			StudentID:  studentID,
+			Date:       date,
+			Time:       now.Format("15:04"),
+			Status:     dir,
+			Note:       dev.Name,
+			RecordedAt: now,
+			Source:     source,
+			DeviceID:   &devID,
+		}
+		h.Att.scheduleOn(date).apply(&rec)
+		rec.StatusCode = attendanceStatusCode(rec.Status)
+		return tx.Create(&rec).Error
+	})
+	if err == gorm.ErrRecordNotFound {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "STUDENT_NOT_FOUND"})
+	}
+	if err == errStudentInactive {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "STUDENT_INACTIVE", "status": stu.Status})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+
+	code := http.StatusCreated
+	if duplicate {
+		code = http.StatusOK
+	}
+	// ข้อมูลสำหรับแสดงบนจอเครื่องสแกน
+	return c.JSON(code, map[string]any{
+		"duplicate": duplicate,
+		"student": map[string]any{
+			"id":        stu.ID,
+			"code":      stu.StudentID,
+			"full_name": strings.Join(strings.Fields(stu.Prefix+" "+stu.FirstName+" "+stu.LastName), " "),
+			"grade":     stu.Grade,
+			"room":      stu.Room,
+		},
+		"attendance_id": rec.ID,
+		"status":        rec.Status,
+		"status_code":   rec.StatusCode,
+		"time":          rec.Time,
+		"minutes_late":  rec.MinutesLate,
+		"early_leave":   rec.EarlyLeave,
+	})
+}
+
+/* ====================== Admin: devices ====================== */
+
+type gateDeviceReq struct {
+	Name      string `json:"name"`
+	Location  string `json:"location"`
+	Direction string `json:"direction"`
+	Enabled   *bool  `json:"enabled"`
+}
+
+func (r *gateDeviceReq) validate() map[string]string {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Location = strings.TrimSpace(r.Location)
+	r.Direction = strings.TrimSpace(r.Direction)
+	if r.Direction == "" {
+		r.Direction = gateDirectionAny
+	}
+	fields := map[string]string{}
+	if r.Name == "" || len([]rune(r.Name)) > 80 {
+		fields["name"] = "required"
+	}
+	if r.Direction != gateDirectionIn && r.Direction != gateDirectionOut && r.Direction != gateDirectionAny {
+		fields["direction"] = "เข้า | ออก | auto"
+	}
+	return fields
+}
+
+func findGateDevice(c echo.Context) (*models.GateDevice, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return nil, c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	var dev models.GateDevice
+	if err := database.DB.First(&dev, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+		}
+		return nil, c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	return &dev, nil
+}
+
+// GET /gate-devices
+func (h *GateHandler) ListDevices(c echo.Context) error {
+	var rows []models.GateDevice
+	if err := database.DB.Order("id ASC").Find(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, rows)
+}
+
+// POST /gate-devices — คืน api_key ครั้งเดียว (ตั้งในเครื่องสแกน)
+func (h *GateHandler) CreateDevice(c echo.Context) error {
+	var req gateDeviceReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	if fields := req.validate(); len(fields) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "VALIDATION_ERROR", "fields": fields})
+	}
+	prefix, key, err := newDeviceKey()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "KEY_GENERATION_FAILED"})
+	}
+	dev := models.GateDevice{
+		Name: req.Name, Location: req.Location, Direction: req.Direction,
+		KeyPrefix: prefix, KeyHash: hashToken(key), Enabled: true,
+	}
+	if p := currentPrincipal(c); p != nil {
+		dev.CreatedBy = p.UserID
+	}
+	if err := database.DB.Create(&dev).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusCreated, map[string]any{"device": dev, "api_key": key})
+}
+
+// PUT /gate-devices/:id
+func (h *GateHandler) UpdateDevice(c echo.Context) error {
+	dev, err := findGateDevice(c)
+	if dev == nil {
+		return err
+	}
+	var req gateDeviceReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	if fields := req.validate(); len(fields) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "VALIDATION_ERROR", "fields": fields})
+	}
+	updates := map[string]any{"name": req.Name, "location": req.Location, "direction": req.Direction}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if err := database.DB.Model(dev).Updates(updates).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusOK, dev)
+}
+
+// POST /gate-devices/:id/rotate-key — key เดิมใช้ไม่ได้ทันที
+func (h *GateHandler) RotateDeviceKey(c echo.Context) error {
+	dev, err := findGateDevice(c)
+	if dev == nil {
+		return err
+	}
+	prefix, key, err := newDeviceKey()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "KEY_GENERATION_FAILED"})
+	}
+	if err := database.DB.Model(dev).Updates(map[string]any{"key_prefix": prefix, "key_hash": hashToken(key)}).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"device": dev, "api_key": key})
+}
+
+// DELETE /gate-devices/:id (แถว attendance เดิมยังอ้าง device_id อยู่)
+func (h *GateHandler) DeleteDevice(c echo.Context) error {
+	dev, err := findGateDevice(c)
+	if dev == nil {
+		return err
+	}
+	if err := database.DB.Delete(dev).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+/* ====================== Admin: student cards ====================== */
+
+// GET /students/:id/cards
+func (h *GateHandler) ListCards(c echo.Context) error {
+	var rows []models.StudentCard
+	if err := database.DB.Where("student_id = ?", c.Param("id")).Order("id DESC").Find(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, rows)
+}
+
+// POST /students/:id/cards
+// body: { card_uid }
+func (h *GateHandler) AddCard(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	var req struct {
+		CardUID string `json:"card_uid"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	uid := normalizeCardUID(req.CardUID)
+	if uid == "" || len(uid) > 64 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+			"error": "VALIDATION_ERROR", "fields": map[string]string{"card_uid": "required"},
+		})
+	}
+	var stu models.Student
+	if err := database.DB.Select("id").First(&stu, id).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+	}
+	var n int64
+	if err := database.DB.Model(&models.StudentCard{}).Where("card_uid = ?", uid).Count(&n).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	if n > 0 {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "CARD_IN_USE"})
+	}
+	card := models.StudentCard{StudentID: stu.ID, CardUID: uid, Active: true}
+	if p := currentPrincipal(c); p != nil {
+		card.CreatedBy = p.UserID
+	}
+	if err := database.DB.Create(&card).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusCreated, card)
+}
+
+// DELETE /student-cards/:id — บัตรหาย/ยกเลิก
+func (h *GateHandler) DeleteCard(c echo.Context) error {
+	res := database.DB.Delete(&models.StudentCard{}, "id = ?", c.Param("id"))
+	if res.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	if res.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
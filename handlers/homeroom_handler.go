@@ -114,6 +114,15 @@ func validateHomeroom(p *homeroomPayload) map[string]string {
 	return errs
 }
 
+// ไม่มีสิทธิ์ classes.all → เห็นเฉพาะห้องที่ตัวเองประจำชั้น (รวมครูคนอื่นในห้องเดียวกัน) ของปีการศึกษาปัจจุบัน
+func scopeHomerooms(p *Principal, tx *gorm.DB) *gorm.DB {
+	s := scopeOf(p)
+	if s.All {
+		return tx
+	}
+	return s.where(tx, "grade", "room").Where("academic_year = ?", currentAcademicYear())
+}
+
 // ========== List ==========
 func (h *HomeroomHandler) List(c echo.Context) error {
 	q := strings.TrimSpace(c.QueryParam("q"))
@@ -132,7 +141,7 @@ func (h *HomeroomHandler) List(c echo.Context) error {
 	}
 
 	var items []models.Homeroom
-	tx := database.DB.Model(&models.Homeroom{})
+	tx := scopeHomerooms(currentPrincipal(c), database.DB.Model(&models.Homeroom{}))
 	if q != "" {
 		like := "%" + q + "%"
 		tx = tx.Where(`
@@ -175,7 +184,7 @@ func (h *HomeroomHandler) List(c echo.Context) error {
 func (h *HomeroomHandler) Get(c echo.Context) error {
 	id := c.Param("id")
 	var r models.Homeroom
-	if err := database.DB.First(&r, "id = ?", id).Error; err != nil {
+	if err := scopeHomerooms(currentPrincipal(c), database.DB.Model(&models.Homeroom{})).First(&r, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "NOT_FOUND"})
 		}
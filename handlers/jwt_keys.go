@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+/*
+	กุญแจเซ็น JWT (RS256 / EdDSA) โหลดจากโฟลเดอร์ JWT_KEYS_DIR (ค่าเริ่มต้น ./keys)
+
+	  <kid>.pem      private key (RSA PKCS#1/PKCS#8 หรือ Ed25519 PKCS#8) → ใช้เซ็น + ตรวจ
+	  <kid>.pub.pem  public key อย่างเดียว (กุญแจที่เลิกเซ็นแล้ว) → ใช้ตรวจอย่างเดียว
+
+	kid = ชื่อไฟล์ไม่รวมนามสกุล, กุญแจที่ใช้เซ็น = JWT_SIGNING_KID หรือ kid ที่เรียงตามชื่อแล้วอยู่ท้ายสุด
+	(ตั้งชื่อไฟล์ขึ้นต้นด้วยวันที่ เช่น 2026-10-ed25519.pem จะได้ตัวใหม่สุดอัตโนมัติ)
+
+	หมุนกุญแจ: วางไฟล์ใหม่ → POST /auth/keys/reload (หรือ restart) → token เก่ายังตรวจผ่านด้วยกุญแจเดิม
+	รอให้ access token เก่าหมดอายุ (JWT_ACCESS_TTL_MINUTES) แล้วค่อยเปลี่ยนไฟล์เก่าเป็น .pub.pem หรือลบทิ้ง
+	refresh token เป็น opaque token ใน DB ไม่ผูกกับกุญแจ → หมุนกุญแจแล้วไม่มีใครหลุดจากระบบ
+*/
+
+type jwtKey struct {
+	Kid     string
+	Alg     string // RS256 | EdDSA
+	Method  jwt.SigningMethod
+	Public  crypto.PublicKey
+	Private crypto.PrivateKey // nil = ใช้ตรวจอย่างเดียว
+}
+
+type keyRing struct {
+	mu      sync.RWMutex
+	dir     string
+	keys    map[string]*jwtKey
+	signing *jwtKey
+}
+
+func newKeyRing(dir string) (*keyRing, error) {
+	kr := &keyRing{dir: dir}
+	if err := kr.Reload(); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// กุญแจชั่วคราวในหน่วยความจำ (dev เท่านั้น: restart แล้ว access token เดิมใช้ไม่ได้ ต้อง refresh)
+func newEphemeralKeyRing() (*keyRing, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	k := &jwtKey{Kid: "dev-ephemeral", Alg: "EdDSA", Method: jwt.SigningMethodEdDSA, Public: pub, Private: priv}
+	return &keyRing{keys: map[string]*jwtKey{k.Kid: k}, signing: k}, nil
+}
+
+// Reload อ่านโฟลเดอร์กุญแจใหม่ทั้งหมด (ถ้าอ่านไม่ผ่าน ใช้ชุดเดิมต่อ)
+func (kr *keyRing) Reload() error {
+	if kr.dir == "" {
+		return errors.New("JWT_KEYS_DIR not set")
+	}
+	files, err := filepath.Glob(filepath.Join(kr.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	keys := map[string]*jwtKey{}
+	var signable []string
+	for _, f := range files {
+		k, err := loadJWTKey(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(f), err)
+		}
+		if _, dup := keys[k.Kid]; dup {
+			return fmt.Errorf("duplicate kid %q", k.Kid)
+		}
+		keys[k.Kid] = k
+		if k.Private != nil {
+			signable = append(signable, k.Kid)
+		}
+	}
+	if len(signable) == 0 {
+		return fmt.Errorf("no private key in %s", kr.dir)
+	}
+
+	kid := strings.TrimSpace(os.Getenv("JWT_SIGNING_KID"))
+	if kid == "" {
+		kid = signable[len(signable)-1]
+	}
+	signing, ok := keys[kid]
+	if !ok || signing.Private == nil {
+		return fmt.Errorf("signing key %q not found", kid)
+	}
+
+	kr.mu.Lock()
+	kr.keys, kr.signing = keys, signing
+	kr.mu.Unlock()
+	return nil
+}
+
+func loadJWTKey(path string) (*jwtKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("not a PEM file")
+	}
+	name := filepath.Base(path)
+	publicOnly := strings.HasSuffix(name, ".pub.pem")
+	k := &jwtKey{Kid: strings.TrimSuffix(strings.TrimSuffix(name, ".pem"), ".pub")}
+
+	var key any
+	switch {
+	case publicOnly:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case block.Type == "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := key.(type) {
+	case *rsa.PrivateKey:
+		k.Private, k.Public = t, &t.PublicKey
+	case *rsa.PublicKey:
+		k.Public = t
+	case ed25519.PrivateKey:
+		k.Private, k.Public = t, t.Public()
+	case ed25519.PublicKey:
+		k.Public = t
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.New("RSA key must be at least 2048 bits")
+		}
+		k.Alg, k.Method = "RS256", jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		k.Alg, k.Method = "EdDSA", jwt.SigningMethodEdDSA
+	}
+	return k, nil
+}
+
+// เซ็น claims ด้วยกุญแจปัจจุบัน (ใส่ kid ใน header ให้ฝั่งตรวจเลือกกุญแจถูก)
+func (kr *keyRing) Sign(claims jwt.Claims) (string, error) {
+	kr.mu.RLock()
+	k := kr.signing
+	kr.mu.RUnlock()
+	tk := jwt.NewWithClaims(k.Method, claims)
+	tk.Header["kid"] = k.Kid
+	return tk.SignedString(k.Private)
+}
+
+// jwt.Keyfunc: เลือกกุญแจจาก kid และบังคับ alg ให้ตรงกับชนิดกุญแจ
+func (kr *keyRing) Keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	kr.mu.RLock()
+	k, ok := kr.keys[kid]
+	kr.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("unknown kid")
+	}
+	if t.Method.Alg() != k.Alg {
+		return nil, errors.New("invalid sign method")
+	}
+	return k.Public, nil
+}
+
+// public keys ทั้งหมดในรูป JWK (RFC 7517)
+func (kr *keyRing) JWKS() []map[string]any {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	kids := make([]string, 0, len(kr.keys))
+	for kid := range kr.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	out := make([]map[string]any, 0, len(kids))
+	for _, kid := range kids {
+		k := kr.keys[kid]
+		jwk := map[string]any{"kid": k.Kid, "alg": k.Alg, "use": "sig"}
+		switch pub := k.Public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		out = append(out, jwk)
+	}
+	return out
+}
+
+// โหลดกุญแจตอนเริ่มระบบ: ไม่มีกุญแจ → dev ใช้กุญแจชั่วคราว, env อื่นหยุดทำงาน
+func loadKeyRingFromEnv() *keyRing {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		dir = "keys"
+	}
+	kr, err := newKeyRing(dir)
+	if err == nil {
+		return kr
+	}
+	env := os.Getenv("APP_ENV")
+	if env != "" && env != "dev" {
+		log.Fatalf("[auth] cannot load JWT keys: %v", err)
+	}
+	log.Printf("[auth] warn: %v — using an ephemeral signing key (dev only)", err)
+	kr, err = newEphemeralKeyRing()
+	if err != nil {
+		log.Fatalf("[auth] cannot generate JWT key: %v", err)
+	}
+	return kr
+}
+
+/* ====================== Handlers ====================== */
+
+// GET /.well-known/jwks.json — public keys สำหรับบริการอื่น (เครื่องสแกนหน้าประตู/ระบบรายงาน) ใช้ตรวจ token
+func (h *AuthHandler) JWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, map[string]any{"keys": h.Keys.JWKS()})
+}
+
+// GET /auth/keys — รายการกุญแจที่โหลดอยู่ (ไม่คืน private key)
+func (h *AuthHandler) ListKeys(c echo.Context) error {
+	h.Keys.mu.RLock()
+	defer h.Keys.mu.RUnlock()
+	out := make([]map[string]any, 0, len(h.Keys.keys))
+	for _, k := range h.Keys.keys {
+		out = append(out, map[string]any{
+			"kid":      k.Kid,
+			"alg":      k.Alg,
+			"signing":  k == h.Keys.signing,
+			"can_sign": k.Private != nil,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i]["kid"].(string) < out[j]["kid"].(string) })
+	return c.JSON(http.StatusOK, out)
+}
+
+// POST /auth/keys/reload — อ่านโฟลเดอร์กุญแจใหม่หลังวาง/ลบไฟล์ (ไม่ต้อง restart)
+func (h *AuthHandler) ReloadKeys(c echo.Context) error {
+	if h.Keys.dir == "" {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "EPHEMERAL_KEY"})
+	}
+	if err := h.Keys.Reload(); err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "KEY_LOAD_FAILED", "detail": err.Error()})
+	}
+	return h.ListKeys(c)
+}
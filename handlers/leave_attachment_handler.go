@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+	"github.com/patiponrmutl/BESystem/storage"
+)
+
+/*
+	ไฟล์แนบใบลา
+
+	  - ผู้ปกครองอัปโหลด (multipart field "file") ได้ขณะใบลายังรออนุมัติหรืออนุมัติแล้ว
+	  - ชนิดไฟล์ตรวจจากเนื้อไฟล์จริง (ไม่เชื่อ Content-Type ที่ส่งมา): PDF / JPEG / PNG
+	  - ดาวน์โหลด: ผู้ปกครองที่ผูกกับนักเรียน หรือครูที่นักเรียนอยู่ในขอบเขต (อื่น ๆ = 404)
+*/
+
+var leaveAttachmentTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+type LeaveAttachmentHandler struct {
+	Store    storage.Storage
+	MaxBytes int64 // ต่อไฟล์
+	MaxFiles int   // ต่อใบลา
+}
+
+func NewLeaveAttachmentHandler() *LeaveAttachmentHandler {
+	return &LeaveAttachmentHandler{
+		Store:    storage.FromEnv(),
+		MaxBytes: int64(atoiOr(os.Getenv("LEAVE_ATTACHMENT_MAX_MB"), 5)) << 20,
+		MaxFiles: atoiOr(os.Getenv("LEAVE_ATTACHMENT_MAX_FILES"), 5),
+	}
+}
+
+/* -------------------- Helpers -------------------- */
+
+// ใบลาที่ผู้ใช้คนนี้เข้าถึงได้: ผู้ปกครอง = ลูกที่ผูกไว้, ครู = นักเรียนในขอบเขต
+func leaveForPrincipal(c echo.Context) (*models.LeaveRequest, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return nil, c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	var row models.LeaveRequest
+	if err := database.DB.First(&row, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+		}
+		return nil, c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	p := currentPrincipal(c)
+	allowed := false
+	if p.IsParent() {
+		allowed = parentHasChild(p.ParentID, row.StudentID)
+	} else if p != nil {
+		allowed = scopeOf(p).allowsStudent(row.StudentID)
+	}
+	if !allowed {
+		return nil, c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+	}
+	return &row, nil
+}
+
+func (h *LeaveAttachmentHandler) findAttachment(c echo.Context, leaveID uint) (*models.LeaveAttachment, error) {
+	aid, err := strconv.ParseUint(c.Param("aid"), 10, 64)
+	if err != nil || aid == 0 {
+		return nil, c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	var a models.LeaveAttachment
+	if err := database.DB.Where("id = ? AND leave_request_id = ?", aid, leaveID).First(&a).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+		}
+		return nil, c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	return &a, nil
+}
+
+// ชื่อไฟล์สำหรับแสดง (ตัด path / ยาวไม่เกิน 255 ตัวอักษร)
+func cleanFileName(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "." || name == "/" || name == "" {
+		name = "file"
+	}
+	for utf8.RuneCountInString(name) > 255 {
+		r := []rune(name)
+		name = string(r[len(r)-255:])
+	}
+	return name
+}
+
+/* -------------------- Handlers -------------------- */
+
+// POST /parent/leave-requests/:id/attachments (multipart: file)
+func (h *LeaveAttachmentHandler) Upload(c echo.Context) error {
+	p := currentPrincipal(c)
+	if !p.IsParent() {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
+	}
+	row, err := leaveForPrincipal(c)
+	if row == nil {
+		return err
+	}
+	if row.Status != leavePending && row.Status != leaveApproved {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "LEAVE_CLOSED", "status": row.Status})
+	}
+	var n int64
+	if err := database.DB.Model(&models.LeaveAttachment{}).Where("leave_request_id = ?", row.ID).Count(&n).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	if int(n) >= h.MaxFiles {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "TOO_MANY_ATTACHMENTS", "max_files": h.MaxFiles})
+	}
+
+	// จำกัดขนาด body ก่อน parse multipart (เผื่อ header ของ multipart 1MB)
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, h.MaxBytes+1<<20)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return c.JSON(http.StatusRequestEntityTooLarge, map[string]any{"error": "FILE_TOO_LARGE", "max_bytes": h.MaxBytes})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "FILE_REQUIRED"})
+	}
+	if fh.Size > h.MaxBytes {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]any{"error": "FILE_TOO_LARGE", "max_bytes": h.MaxBytes})
+	}
+	if fh.Size == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "FILE_EMPTY"})
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "FILE_REQUIRED"})
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	nr, _ := io.ReadFull(f, head)
+	contentType := http.DetectContentType(head[:nr])
+	ext, ok := leaveAttachmentTypes[contentType]
+	if !ok {
+		return c.JSON(http.StatusUnsupportedMediaType, map[string]any{"error": "UNSUPPORTED_FILE_TYPE", "content_type": contentType})
+	}
+
+	tok, err := newOpaqueToken(18)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "STORAGE_ERROR"})
+	}
+	key := fmt.Sprintf("leave/%d/%s%s", row.ID, tok, ext)
+	body := io.MultiReader(bytes.NewReader(head[:nr]), f)
+	if err := h.Store.Put(c.Request().Context(), key, body, fh.Size, contentType); err != nil {
+		c.Logger().Errorf("leave attachment put: %v", err)
+		return c.JSON(http.StatusBadGateway, map[string]any{"error": "STORAGE_ERROR"})
+	}
+
+	a := models.LeaveAttachment{
+		LeaveRequestID: row.ID,
+		FileName:       cleanFileName(fh.Filename),
+		ContentType:    contentType,
+		Size:           fh.Size,
+		StorageKey:     key,
+		UploadedBy:     p.ParentID,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&a).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.LeaveRequest{}).Where("id = ?", row.ID).
+			UpdateColumn("attachments", gorm.Expr("attachments + 1")).Error
+	})
+	if err != nil {
+		_ = h.Store.Delete(c.Request().Context(), key)
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusCreated, a)
+}
+
+// GET /leave-requests/:id/attachments | /parent/leave-requests/:id/attachments
+func (h *LeaveAttachmentHandler) List(c echo.Context) error {
+	row, err := leaveForPrincipal(c)
+	if row == nil {
+		return err
+	}
+	rows := []models.LeaveAttachment{}
+	if err := database.DB.Where("leave_request_id = ?", row.ID).Order("id").Find(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, rows)
+}
+
+// GET /leave-requests/:id/attachments/:aid | /parent/leave-requests/:id/attachments/:aid
+func (h *LeaveAttachmentHandler) Download(c echo.Context) error {
+	row, err := leaveForPrincipal(c)
+	if row == nil {
+		return err
+	}
+	a, err := h.findAttachment(c, row.ID)
+	if a == nil {
+		return err
+	}
+	rc, err := h.Store.Get(c.Request().Context(), a.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "FILE_MISSING"})
+	}
+	if err != nil {
+		c.Logger().Errorf("leave attachment get: %v", err)
+		return c.JSON(http.StatusBadGateway, map[string]any{"error": "STORAGE_ERROR"})
+	}
+	defer rc.Close()
+
+	hd := c.Response().Header()
+	hd.Set(echo.HeaderContentDisposition, "inline; filename*=UTF-8''"+url.PathEscape(a.FileName))
+	hd.Set("X-Content-Type-Options", "nosniff")
+	hd.Set(echo.HeaderContentLength, strconv.FormatInt(a.Size, 10))
+	return c.Stream(http.StatusOK, a.ContentType, rc)
+}
+
+// DELETE /parent/leave-requests/:id/attachments/:aid — เฉพาะคนที่อัปโหลด และใบลายังรออนุมัติ
+func (h *LeaveAttachmentHandler) Delete(c echo.Context) error {
+	p := currentPrincipal(c)
+	if !p.IsParent() {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
+	}
+	row, err := leaveForPrincipal(c)
+	if row == nil {
+		return err
+	}
+	a, err := h.findAttachment(c, row.ID)
+	if a == nil {
+		return err
+	}
+	if a.UploadedBy != p.ParentID {
+		return c.JSON(http.StatusForbidden, map[string]any{"error": "NOT_UPLOADER"})
+	}
+	if row.Status != leavePending {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "LEAVE_NOT_PENDING", "status": row.Status})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(a).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.LeaveRequest{}).Where("id = ? AND attachments > 0", row.ID).
+			UpdateColumn("attachments", gorm.Expr("attachments - 1")).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_DELETE_ERROR"})
+	}
+	// ลบไฟล์จริงทีหลัง (ลบไม่สำเร็จ = เหลือไฟล์กำพร้า ไม่กระทบข้อมูล)
+	if err := h.Store.Delete(c.Request().Context(), a.StorageKey); err != nil {
+		c.Logger().Errorf("leave attachment delete: %v", err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/*
+	ใบลาที่อนุมัติ → แถว attendance จริง (สถานะ "ลา", note = ประเภทการลา)
+
+	- เขียนเฉพาะวันเรียนในช่วง DateFrom..DateTo (ข้ามเสาร์-อาทิตย์/วันหยุด/นอกภาคเรียน)
+	- วันที่มาเรียนแล้ว (หมวด present) → ไม่แตะ
+	- แถวเดิมของวันนั้น (ขาด/ยังไม่เข้า/ลา) → ยกเลิก + จำว่าถูกแทนที่ด้วยใบลาไหน (voided_by_leave_id)
+	- ใบลาถูกยกเลิก/เพิกถอน → ยกเลิกแถว "ลา" ของใบลา แล้วคืนแถวเดิมที่ถูกแทนที่
+	ทุกการเปลี่ยนแปลงเขียน attendance_revisions
+*/
+
+const attendanceSourceLeave = "leave"
+
+// เขียนแถว "ลา" ของใบลาที่เพิ่งอนุมัติ (เรียกภายใน transaction เดียวกับการเปลี่ยนสถานะ)
+func applyLeaveAttendance(tx *gorm.DB, lv *models.LeaveRequest, by *uint) (int, error) {
+	days := loadSchoolCalendar(lv.DateFrom, lv.DateTo).schoolDays(lv.DateFrom, lv.DateTo)
+	if len(days) == 0 {
+		return 0, nil
+	}
+	var existing []models.Attendance
+	if err := tx.Where("student_id = ? AND date IN ? AND voided_at IS NULL", lv.StudentID, days).
+		Order("id").Find(&existing).Error; err != nil {
+		return 0, err
+	}
+	byDate := map[string][]models.Attendance{}
+	for _, a := range existing {
+		byDate[a.Date] = append(byDate[a.Date], a)
+	}
+
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	reason := fmt.Sprintf("แทนที่ด้วยใบลา #%d", lv.ID)
+	written := 0
+	for _, date := range days {
+		skip := false
+		for _, a := range byDate[date] {
+			st, _ := findAttendanceStatus(a.Status)
+			if st.Category == statusCategoryPresent || (a.LeaveRequestID != nil && *a.LeaveRequestID == lv.ID) {
+				skip = true // มาเรียนแล้ว / เขียนไปแล้ว
+				break
+			}
+		}
+		if skip {
+			continue
+		}
+		for _, a := range byDate[date] {
+			if err := tx.Model(&models.Attendance{}).Where("id = ?", a.ID).
+				Updates(map[string]any{"voided_at": now, "voided_by_leave_id": lv.ID}).Error; err != nil {
+				return 0, err
+			}
+			if err := writeLeaveRevision(tx, &a, correctionVoid, reason, by, now); err != nil {
+				return 0, err
+			}
+		}
+		leaveID := lv.ID
+		row := models.Attendance{
+			StudentID:      lv.StudentID,
+			Date:           date,
+			Time:           "—",
+			Status:         attendanceStatusLabel(statusCodeLeave),
+			StatusCode:     statusCodeLeave,
+			Note:           lv.Type,
+			RecordedBy:     by,
+			Retro:          date < today,
+			RecordedAt:     now,
+			Source:         attendanceSourceLeave,
+			LeaveRequestID: &leaveID,
+		}
+		if err := tx.Create(&row).Error; err != nil {
+			return 0, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+// ยกเลิกแถว "ลา" ของใบลา + คืนแถวเดิมที่ถูกแทนที่ (ใบลาถูกยกเลิก/เพิกถอนหลังอนุมัติ)
+func revertLeaveAttendance(tx *gorm.DB, leaveID uint, by *uint, reason string) error {
+	now := time.Now()
+	// เฉพาะแถวที่ยังเป็น "ลา" (แถวที่ครูแก้เป็นสถานะอื่นแล้วไม่แตะ)
+	var own []models.Attendance
+	if err := tx.Where("leave_request_id = ? AND status_code = ? AND voided_at IS NULL", leaveID, statusCodeLeave).
+		Find(&own).Error; err != nil {
+		return err
+	}
+	for _, a := range own {
+		if err := tx.Model(&models.Attendance{}).Where("id = ?", a.ID).Update("voided_at", now).Error; err != nil {
+			return err
+		}
+		if err := writeLeaveRevision(tx, &a, correctionVoid, reason, by, now); err != nil {
+			return err
+		}
+	}
+
+	var replaced []models.Attendance
+	if err := tx.Where("voided_by_leave_id = ?", leaveID).Find(&replaced).Error; err != nil {
+		return err
+	}
+	for _, a := range replaced {
+		// วันนั้นมีแถวใหม่กว่าที่ยังใช้อยู่ (เช่น ครูบันทึก/แก้ภายหลัง) → ไม่คืนแถวเดิม
+		var newer int64
+		if err := tx.Model(&models.Attendance{}).
+			Where("student_id = ? AND date = ? AND id > ? AND voided_at IS NULL", a.StudentID, a.Date, a.ID).
+			Count(&newer).Error; err != nil {
+			return err
+		}
+		if newer > 0 {
+			continue
+		}
+		if err := tx.Model(&models.Attendance{}).Where("id = ?", a.ID).
+			Updates(map[string]any{"voided_at": nil, "voided_by_leave_id": nil}).Error; err != nil {
+			return err
+		}
+		if err := writeLeaveRevision(tx, &a, "restore", reason, by, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revision ของการเปลี่ยนแปลงจากใบลา (สถานะ/เวลา/note ไม่เปลี่ยน — เปลี่ยนแค่ยกเลิก/คืนค่า)
+func writeLeaveRevision(tx *gorm.DB, a *models.Attendance, action, reason string, by *uint, at time.Time) error {
+	return tx.Create(&models.AttendanceRevision{
+		AttendanceID: a.ID,
+		Action:       action,
+		OldStatus:    a.Status,
+		NewStatus:    a.Status,
+		OldTime:      a.Time,
+		NewTime:      a.Time,
+		OldNote:      a.Note,
+		NewNote:      a.Note,
+		Reason:       reason,
+		ChangedBy:    by,
+		ChangedAt:    at,
+	}).Error
+}
+
+// ผลของการเปลี่ยนสถานะใบลาต่อ attendance (เรียกจาก transitionLeave)
+func syncLeaveAttendance(tx *gorm.DB, row *models.LeaveRequest, from string, p *Principal) error {
+	var by *uint
+	if p != nil && p.UserID != 0 {
+		uid := p.UserID
+		by = &uid
+	}
+	switch {
+	case row.Status == leaveApproved && from != leaveApproved:
+		_, err := applyLeaveAttendance(tx, row, by)
+		return err
+	case from == leaveApproved && row.Status != leaveApproved:
+		return revertLeaveAttendance(tx, row.ID, by, fmt.Sprintf("ใบลา #%d %s", row.ID, row.Status))
+	}
+	return nil
+}
+
+// BackfillLeaveAttendance เขียนแถว "ลา" ให้ใบลาที่อนุมัติไว้ก่อนมีฟีเจอร์นี้ (เรียกตอนเริ่มระบบ)
+func BackfillLeaveAttendance() error {
+	var leaves []models.LeaveRequest
+	if err := database.DB.
+		Where("status = ?", leaveApproved).
+		Where("NOT EXISTS (SELECT 1 FROM attendances a WHERE a.leave_request_id = leave_requests.id)").
+		Order("id").Find(&leaves).Error; err != nil {
+		return err
+	}
+	total := 0
+	for i := range leaves {
+		lv := &leaves[i]
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			n, err := applyLeaveAttendance(tx, lv, lv.DecidedBy)
+			total += n
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if total > 0 {
+		log.Printf("[bootstrap] leave attendance backfilled: %d rows from %d approved leave(s)", total, len(leaves))
+	}
+	return nil
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
@@ -38,7 +37,7 @@ func (h *LeaveRequestHandler) List(c echo.Context) error {
 		size = 10
 	}
 
-	tx := database.DB.Model(&models.LeaveRequest{})
+	tx := scopeOf(currentPrincipal(c)).students(database.DB.Model(&models.LeaveRequest{}), "student_id")
 
 	if status != "" {
 		tx = tx.Where("status = ?", status)
@@ -70,7 +69,7 @@ func (h *LeaveRequestHandler) List(c echo.Context) error {
 // GET /teacher/leave-requests/pending-count
 func (h *LeaveRequestHandler) PendingCount(c echo.Context) error {
 	var n int64
-	if err := database.DB.Model(&models.LeaveRequest{}).
+	if err := scopeOf(currentPrincipal(c)).students(database.DB.Model(&models.LeaveRequest{}), "student_id").
 		Where("status = ?", "รออนุมัติ").Count(&n).Error; err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
 	}
@@ -85,7 +84,7 @@ type updateReq struct {
 // POST /teacher/leave-requests/:id/approve
 func (h *LeaveRequestHandler) Approve(c echo.Context) error {
 	id := c.Param("id")
-	return h.updateStatus(c, id, updateReq{Status: "อนุมัติ"})
+	return h.updateStatus(c, id, updateReq{Status: leaveApproved})
 }
 
 // POST /teacher/leave-requests/:id/reject
@@ -95,13 +94,16 @@ func (h *LeaveRequestHandler) Reject(c echo.Context) error {
 	if err := c.Bind(&body); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
 	}
-	body.Status = "ปฏิเสธ"
+	body.Status = leaveRejected
 	return h.updateStatus(c, id, body)
 }
 
 func (h *LeaveRequestHandler) updateStatus(c echo.Context, id string, body updateReq) error {
 	var row models.LeaveRequest
-	if err := database.DB.First(&row, "id = ?", id).Error; err != nil {
+	// ใบลาของนักเรียนนอกห้องที่ดูแล → 404 เหมือนไม่มีอยู่
+	p := currentPrincipal(c)
+	tx := scopeOf(p).students(database.DB.Model(&models.LeaveRequest{}), "student_id")
+	if err := tx.First(&row, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
 		}
@@ -109,84 +111,40 @@ func (h *LeaveRequestHandler) updateStatus(c echo.Context, id string, body updat
 	}
 
 	// ตรวจความถูกต้อง
-	if body.Status == "ปฏิเสธ" && strings.TrimSpace(body.RejectReason) == "" {
+	body.RejectReason = strings.TrimSpace(body.RejectReason)
+	if body.Status == leaveRejected && body.RejectReason == "" {
 		return c.JSON(http.StatusBadRequest, map[string]any{"error": "REJECT_REASON_REQUIRED"})
 	}
 
+	action := leaveActApprove
+	if body.Status == leaveRejected {
+		action = leaveActReject
+	}
 	now := time.Now()
 	updates := map[string]any{
-		"status":     body.Status,
-		"decided_at": &now,
-	}
-	// เก็บ user_id คนอนุมัติ/ปฏิเสธ ถ้ามีใน context JWT
-	if uid, _ := getUserID(c); uid > 0 {
-		updates["decided_by"] = uid
+		"decided_at":    &now,
+		"reject_reason": body.RejectReason,
 	}
-	if body.Status == "ปฏิเสธ" {
-		updates["reject_reason"] = strings.TrimSpace(body.RejectReason)
-	} else {
-		updates["reject_reason"] = ""
+	// เก็บ user_id คนอนุมัติ/ปฏิเสธ
+	if p != nil && p.UserID > 0 {
+		updates["decided_by"] = p.UserID
 	}
 
-	if err := database.DB.Model(&models.LeaveRequest{}).Where("id = ?", row.ID).Updates(updates).Error; err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+	// ตัดสินได้เฉพาะใบที่ยังรออนุมัติ (ผู้ปกครองอาจยกเลิกไปแล้ว)
+	// และเฉพาะผู้อนุมัติปัจจุบัน — ตรวจบนแถวที่ล็อกแล้ว (งานส่งต่ออาจเปลี่ยนผู้อนุมัติระหว่างนั้น)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := transitionLeave(tx, row.ID, action, p, leaveChange{
+			Reason: body.RejectReason, Updates: updates, Check: requireApprover(p),
+		})
+		return err
+	})
+	if err != nil {
+		return leaveTransitionFailed(c, err)
 	}
 	return c.JSON(http.StatusOK, map[string]any{"ok": true})
 }
 
-// helper สำหรับอ่าน user_id จาก JWT middleware ที่คุณมีอยู่
-func getUserID(c echo.Context) (uint, bool) {
-	switch v := c.Get("user_id").(type) {
-	case uint:
-		return v, true
-	case int:
-		return uint(v), true
-	default:
-		return 0, false
-	}
-}
-
 // ใช้ fmt.Sscanf โดยเลี่ยง import fmt ตรง ๆ
 func fmtSscanf(str string, format string, a ...any) (int, error) {
 	return fmtSscanfImpl(str, format, a...)
 }
-
-func (h *LeaveRequestHandler) Get(c echo.Context) error {
-	status := c.QueryParam("status")
-	studentID := c.QueryParam("student_id")
-
-	page, _ := strconv.Atoi(c.QueryParam("page"))
-	if page < 1 {
-		page = 1
-	}
-	size, _ := strconv.Atoi(c.QueryParam("size"))
-	if size < 1 || size > 200 {
-		size = 50
-	}
-	offset := (page - 1) * size
-
-	var q *gorm.DB = database.DB.Model(&models.LeaveRequest{})
-	if status != "" {
-		q = q.Where("status = ?", status)
-	}
-	if studentID != "" {
-		q = q.Where("student_id = ?", studentID)
-	}
-
-	var total int64
-	if err := q.Count(&total).Error; err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": err.Error()})
-	}
-
-	var items []models.LeaveRequest
-	if err := q.Order("id DESC").Limit(size).Offset(offset).Find(&items).Error; err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": err.Error()})
-	}
-
-	return c.JSON(http.StatusOK, map[string]any{
-		"items": items,
-		"page":  page,
-		"size":  size,
-		"total": total,
-	})
-}
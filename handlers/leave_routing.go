@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/*
+	เส้นทางอนุมัติใบลา
+
+	  - ยื่นใบลา → ครูประจำชั้นหลักของห้องนักเรียน (ปีการศึกษาปัจจุบัน, ปฏิบัติงาน)
+	  - ค้างเกิน LEAVE_ESCALATE_SCHOOL_DAYS วันเรียน → ครูประจำชั้นรอง → admin (สิทธิ์ leave.admin)
+	  - ระดับที่ไม่มีครู → ข้ามไประดับถัดไปทันที
+	  - ตัดสินได้เฉพาะผู้อนุมัติปัจจุบัน หรือผู้มีสิทธิ์ leave.admin
+	  - ทุกครั้งที่ส่งต่อบันทึกลง leave_routings
+*/
+
+const (
+	approverMain      = "main"
+	approverSecondary = "secondary"
+	approverAdmin     = "admin"
+
+	routeSubmitted = "submitted"
+	routeEscalated = "escalated"
+	routeNoTeacher = "no_teacher" // ระดับนี้ไม่มีครู → ข้าม
+)
+
+var approverPositions = map[string]string{
+	approverMain:      "ครูประจำชั้นหลัก",
+	approverSecondary: "ครูประจำชั้นรอง",
+}
+
+var nextApproverLevel = map[string]string{
+	"":                approverMain,
+	approverMain:      approverSecondary,
+	approverSecondary: approverAdmin,
+}
+
+// ครูประจำชั้นตำแหน่ง position ของห้องที่นักเรียนอยู่ (nil = ไม่มี)
+func homeroomTeacherOf(tx *gorm.DB, studentID uint, position string) *uint {
+	var stu models.Student
+	if err := tx.Select("id, grade, room").First(&stu, studentID).Error; err != nil {
+		return nil
+	}
+	var hr models.Homeroom
+	if err := tx.
+		Where("grade = ? AND room = ? AND position = ? AND status = ? AND academic_year = ?",
+			strings.TrimSpace(stu.Grade), strings.TrimSpace(stu.Room), position, homeroomActive, currentAcademicYear()).
+		Order("id DESC").
+		First(&hr).Error; err != nil {
+		return nil
+	}
+	tid := hr.TeacherID
+	return &tid
+}
+
+// ส่งใบลาไประดับ level (ข้ามระดับที่ไม่มีครู) แล้วบันทึกประวัติ
+func routeLeave(tx *gorm.DB, row *models.LeaveRequest, level, reason string, now time.Time) error {
+	var teacherID *uint
+	for level != approverAdmin {
+		if teacherID = homeroomTeacherOf(tx, row.StudentID, approverPositions[level]); teacherID != nil {
+			break
+		}
+		if err := tx.Create(&models.LeaveRouting{
+			LeaveRequestID: row.ID, Level: level, Reason: routeNoTeacher, RoutedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+		level = nextApproverLevel[level]
+	}
+	if err := tx.Create(&models.LeaveRouting{
+		LeaveRequestID: row.ID, Level: level, TeacherID: teacherID, Reason: reason, RoutedAt: now,
+	}).Error; err != nil {
+		return err
+	}
+	row.ApproverLevel, row.ApproverTeacherID, row.RoutedAt = level, teacherID, &now
+	return tx.Model(&models.LeaveRequest{}).Where("id = ?", row.ID).Updates(map[string]any{
+		"approver_level": level, "approver_teacher_id": teacherID, "routed_at": &now,
+	}).Error
+}
+
+// ผู้ใช้คนนี้ตัดสินใบลานี้ได้ไหม
+func canDecideLeave(p *Principal, row *models.LeaveRequest) bool {
+	if p.Can(PermLeaveAdmin) {
+		return true
+	}
+	if row.ApproverLevel == "" {
+		// ใบเก่าที่ยังไม่ถูกส่ง (งานส่งต่อจะจัดให้) → ครูในขอบเขตตัดสินได้เหมือนเดิม
+		return scopeOf(p).allowsStudent(row.StudentID)
+	}
+	return p != nil && p.TeacherID > 0 && row.ApproverLevel != approverAdmin &&
+		row.ApproverTeacherID != nil && *row.ApproverTeacherID == p.TeacherID
+}
+
+// ใช้เป็น leaveChange.Check: ตรวจผู้อนุมัติบนแถวที่ล็อกภายใน transitionLeave
+func requireApprover(p *Principal) func(*models.LeaveRequest) error {
+	return func(row *models.LeaveRequest) error {
+		if !canDecideLeave(p, row) {
+			return &errLeaveCode{http.StatusForbidden, "NOT_CURRENT_APPROVER"}
+		}
+		return nil
+	}
+}
+
+/* ====================== งานส่งต่ออัตโนมัติ ====================== */
+
+type LeaveEscalationJob struct {
+	SchoolDays int // ค้างกี่วันเรียนแล้วส่งต่อ
+}
+
+// StartLeaveEscalationJob เริ่ม loop ตรวจใบลาค้างทุก 15 นาที (เรียกครั้งเดียวตอนเริ่มระบบ)
+func StartLeaveEscalationJob() {
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("LEAVE_ESCALATION_ENABLED"))); v == "false" || v == "0" {
+		log.Printf("[leave-escalation] disabled")
+		return
+	}
+	j := &LeaveEscalationJob{SchoolDays: atoiOr(os.Getenv("LEAVE_ESCALATE_SCHOOL_DAYS"), 2)}
+	if j.SchoolDays < 1 {
+		j.SchoolDays = 1
+	}
+	go func() {
+		j.tick(time.Now())
+		t := time.NewTicker(15 * time.Minute)
+		defer t.Stop()
+		for now := range t.C {
+			j.tick(now)
+		}
+	}()
+}
+
+func (j *LeaveEscalationJob) tick(now time.Time) {
+	var rows []models.LeaveRequest
+	if err := database.DB.
+		Where("status = ? AND approver_level IN ?", leavePending, []string{"", approverMain, approverSecondary}).
+		Order("id").
+		Find(&rows).Error; err != nil {
+		log.Printf("[leave-escalation] query failed: %v", err)
+		return
+	}
+	if len(rows) == 0 {
+		return
+	}
+	today := now.Format("2006-01-02")
+	from := today
+	for _, r := range rows {
+		if r.RoutedAt != nil && r.RoutedAt.Format("2006-01-02") < from {
+			from = r.RoutedAt.Format("2006-01-02")
+		}
+	}
+	cal := loadSchoolCalendar(from, today)
+
+	moved := 0
+	for _, r := range rows {
+		level, reason := nextApproverLevel[r.ApproverLevel], routeEscalated
+		if r.ApproverLevel == "" || r.RoutedAt == nil {
+			level, reason = approverMain, routeSubmitted // ใบเก่าก่อนมีการส่งต่อ
+		} else {
+			// นับวันเรียนหลังวันที่ส่ง (ไม่นับวันส่ง) จนถึงวันนี้
+			start := r.RoutedAt.AddDate(0, 0, 1).Format("2006-01-02")
+			if start > today || len(cal.schoolDays(start, today)) < j.SchoolDays {
+				continue
+			}
+		}
+		routed := false
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var cur models.LeaveRequest
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&cur, r.ID).Error; err != nil {
+				return err
+			}
+			// ถูกตัดสิน/ส่งต่อไปแล้วระหว่างนี้
+			if cur.Status != leavePending || cur.ApproverLevel != r.ApproverLevel {
+				return nil
+			}
+			routed = true
+			return routeLeave(tx, &cur, level, reason, now)
+		})
+		if err != nil {
+			log.Printf("[leave-escalation] leave %d failed: %v", r.ID, err)
+		} else if routed {
+			moved++
+		}
+	}
+	if moved > 0 {
+		log.Printf("[leave-escalation] routed %d leave request(s)", moved)
+	}
+}
+
+/* ====================== Handlers ====================== */
+
+// GET /leave-requests/my-approvals — ใบลารออนุมัติที่ส่งถึงผู้ใช้คนนี้
+// (ครู = ใบที่ตัวเองเป็นผู้อนุมัติปัจจุบัน, leave.admin = รวมใบที่ส่งต่อถึง admin)
+func (h *LeaveRequestHandler) MyApprovals(c echo.Context) error {
+	p := currentPrincipal(c)
+	type row struct {
+		models.LeaveRequest
+		StudentCode string `json:"student_code"`
+		StudentName string `json:"student_name"`
+		Grade       string `json:"grade"`
+		Room        string `json:"room"`
+	}
+
+	tx := database.DB.Table("leave_requests AS l").
+		Joins("JOIN students s ON s.id = l.student_id").
+		Where("l.status = ?", leavePending)
+	switch {
+	case p.TeacherID > 0 && p.Can(PermLeaveAdmin):
+		tx = tx.Where("(l.approver_teacher_id = ? AND l.approver_level IN ?) OR l.approver_level = ?",
+			p.TeacherID, []string{approverMain, approverSecondary}, approverAdmin)
+	case p.TeacherID > 0:
+		tx = tx.Where("l.approver_teacher_id = ? AND l.approver_level IN ?",
+			p.TeacherID, []string{approverMain, approverSecondary})
+	case p.Can(PermLeaveAdmin):
+		tx = tx.Where("l.approver_level = ?", approverAdmin)
+	default:
+		return c.JSON(http.StatusOK, []row{})
+	}
+
+	rows := []row{}
+	if err := tx.Select(`l.*, s.student_id AS student_code,
+			TRIM(CONCAT(s.prefix, s.first_name, ' ', s.last_name)) AS student_name, s.grade, s.room`).
+		Order("l.routed_at ASC, l.id ASC").
+		Scan(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, rows)
+}
+
+// GET /leave-requests/:id/routing — ประวัติการส่งต่อผู้อนุมัติ
+func (h *LeaveRequestHandler) Routing(c echo.Context) error {
+	row, err := leaveForPrincipal(c)
+	if row == nil {
+		return err
+	}
+	items := []models.LeaveRouting{}
+	if err := database.DB.Where("leave_request_id = ?", row.ID).Order("id").Find(&items).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"approver_level":      row.ApproverLevel,
+		"approver_teacher_id": row.ApproverTeacherID,
+		"routed_at":           row.RoutedAt,
+		"items":               items,
+	})
+}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/*
+	สถานะใบลา (state machine)
+
+	  submit   ─            → รออนุมัติ   ผู้ปกครอง
+	  amend    รออนุมัติ    → รออนุมัติ   ผู้ปกครองที่ยื่น (แก้วันที่/ประเภท/เหตุผล)
+	  approve  รออนุมัติ    → อนุมัติ     ผู้อนุมัติปัจจุบัน / leave.admin
+	  reject   รออนุมัติ    → ปฏิเสธ      ผู้อนุมัติปัจจุบัน / leave.admin (ต้องมีเหตุผล)
+	  cancel   รออนุมัติ    → ยกเลิก      ผู้ปกครองที่ยื่น
+	           อนุมัติ      → ยกเลิก      ผู้ปกครองที่ยื่น (เฉพาะก่อนถึงวันเริ่มลา)
+	  revoke   อนุมัติ      → เพิกถอน     leave.admin (ต้องมีเหตุผล)
+
+	นอกจากนี้ = ILLEGAL_TRANSITION (409) และทุกการเปลี่ยนบันทึกลง leave_transitions
+*/
+
+const (
+	leaveActSubmit  = "submit"
+	leaveActAmend   = "amend"
+	leaveActApprove = "approve"
+	leaveActReject  = "reject"
+	leaveActCancel  = "cancel"
+	leaveActRevoke  = "revoke"
+)
+
+var leaveTransitions = map[string]struct {
+	From []string
+	To   string
+}{
+	leaveActSubmit:  {[]string{""}, leavePending},
+	leaveActAmend:   {[]string{leavePending}, leavePending},
+	leaveActApprove: {[]string{leavePending}, leaveApproved},
+	leaveActReject:  {[]string{leavePending}, leaveRejected},
+	leaveActCancel:  {[]string{leavePending, leaveApproved}, leaveCancelled},
+	leaveActRevoke:  {[]string{leaveApproved}, leaveRevoked},
+}
+
+// ลำดับที่แสดงให้ FE
+var leaveActionOrder = []string{leaveActAmend, leaveActApprove, leaveActReject, leaveActCancel, leaveActRevoke}
+
+type illegalLeaveTransition struct {
+	From   string
+	Action string
+}
+
+func (e *illegalLeaveTransition) Error() string {
+	return "illegal leave transition: " + e.Action + " from " + e.From
+}
+
+// errLeaveCode = เงื่อนไขเฉพาะของ action ไม่ผ่าน (คืน error code ให้ handler ตอบ)
+type errLeaveCode struct {
+	Status int
+	Code   string
+}
+
+func (e *errLeaveCode) Error() string { return e.Code }
+
+func leaveCanTransition(status, action string) bool {
+	for _, f := range leaveTransitions[action].From {
+		if f == status {
+			return true
+		}
+	}
+	return false
+}
+
+// action ที่สถานะนี้ทำต่อได้ (ยังไม่ดูว่าใครทำ)
+func leaveActionsFor(status string) []string {
+	out := []string{}
+	for _, a := range leaveActionOrder {
+		if leaveCanTransition(status, a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+type leaveChange struct {
+	Reason  string
+	Changes map[string][2]string // field → {เดิม, ใหม่}
+	Updates map[string]any       // คอลัมน์อื่นที่อัปเดตพร้อมสถานะ
+	Check   func(row *models.LeaveRequest) error
+}
+
+func recordLeaveTransition(tx *gorm.DB, leaveID uint, action, from, to string, p *Principal, ch leaveChange) error {
+	t := models.LeaveTransition{
+		LeaveRequestID: leaveID, Action: action, FromStatus: from, ToStatus: to, Reason: ch.Reason,
+	}
+	if len(ch.Changes) > 0 {
+		b, _ := json.Marshal(ch.Changes)
+		t.Changes = string(b)
+	}
+	if p != nil && p.UserID > 0 {
+		uid := p.UserID
+		t.ActorUserID = &uid
+	}
+	if p != nil && p.ParentID > 0 {
+		pid := p.ParentID
+		t.ActorParentID = &pid
+	}
+	return tx.Create(&t).Error
+}
+
+// เปลี่ยนสถานะใบลา id ตาม action (ล็อกแถวก่อนตรวจ) + บันทึกประวัติ — เรียกภายใน transaction
+func transitionLeave(tx *gorm.DB, id uint, action string, p *Principal, ch leaveChange) (*models.LeaveRequest, error) {
+	var row models.LeaveRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&row, id).Error; err != nil {
+		return nil, err
+	}
+	if !leaveCanTransition(row.Status, action) {
+		return nil, &illegalLeaveTransition{From: row.Status, Action: action}
+	}
+	if ch.Check != nil {
+		if err := ch.Check(&row); err != nil {
+			return nil, err
+		}
+	}
+	from, to := row.Status, leaveTransitions[action].To
+	updates := map[string]any{"status": to}
+	for k, v := range ch.Updates {
+		updates[k] = v
+	}
+	if err := tx.Model(&models.LeaveRequest{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	if err := recordLeaveTransition(tx, id, action, from, to, p, ch); err != nil {
+		return nil, err
+	}
+	if err := tx.First(&row, id).Error; err != nil {
+		return nil, err
+	}
+	// อนุมัติ → เขียนแถว "ลา"; ยกเลิก/เพิกถอนหลังอนุมัติ → คืนค่าแถวเดิม
+	if err := syncLeaveAttendance(tx, &row, from, p); err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// แปลง error จาก transitionLeave เป็น response
+func leaveTransitionFailed(c echo.Context, err error) error {
+	var illegal *illegalLeaveTransition
+	var coded *errLeaveCode
+	switch {
+	case errors.As(err, &illegal):
+		return c.JSON(http.StatusConflict, map[string]any{
+			"error": "ILLEGAL_TRANSITION", "status": illegal.From, "action": illegal.Action,
+			"allowed_actions": leaveActionsFor(illegal.From),
+		})
+	case errors.As(err, &coded):
+		return c.JSON(coded.Status, map[string]any{"error": coded.Code})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+}
+
+// ใบลา + ประวัติสถานะ + ประวัติการส่งต่อ
+func leaveDetail(row *models.LeaveRequest) (map[string]any, error) {
+	history := []models.LeaveTransition{}
+	if err := database.DB.Where("leave_request_id = ?", row.ID).Order("id").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	routing := []models.LeaveRouting{}
+	if err := database.DB.Where("leave_request_id = ?", row.ID).Order("id").Find(&routing).Error; err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"leave_request":   row,
+		"history":         history,
+		"routing":         routing,
+		"allowed_actions": leaveActionsFor(row.Status),
+	}, nil
+}
+
+// เฉพาะผู้ปกครองที่ยื่นใบนี้
+func requireSubmitter(p *Principal) func(*models.LeaveRequest) error {
+	return func(row *models.LeaveRequest) error {
+		if row.ParentID == nil || *row.ParentID != p.ParentID {
+			return &errLeaveCode{http.StatusForbidden, "NOT_SUBMITTER"}
+		}
+		return nil
+	}
+}
+
+// ยกเลิกได้เฉพาะผู้ยื่น; ใบที่อนุมัติแล้วยกเลิกได้ก่อนถึงวันเริ่มลาเท่านั้น
+func leaveCancelCheck(p *Principal, now time.Time) func(*models.LeaveRequest) error {
+	return func(row *models.LeaveRequest) error {
+		if err := requireSubmitter(p)(row); err != nil {
+			return err
+		}
+		if row.Status == leaveApproved && row.DateFrom <= now.Format("2006-01-02") {
+			return &errLeaveCode{http.StatusConflict, "LEAVE_ALREADY_STARTED"}
+		}
+		return nil
+	}
+}
+
+/* ====================== Staff ====================== */
+
+// GET /leave-requests/:id — ใบลา + ประวัติ
+func (h *LeaveRequestHandler) Get(c echo.Context) error {
+	row, err := leaveForPrincipal(c)
+	if row == nil {
+		return err
+	}
+	out, err := leaveDetail(row)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+// POST /leave-requests/:id/revoke
+// body: { reason } — เพิกถอนใบที่อนุมัติแล้ว (leave.admin)
+func (h *LeaveRequestHandler) Revoke(c echo.Context) error {
+	row, err := leaveForPrincipal(c)
+	if row == nil {
+		return err
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "REASON_REQUIRED"})
+	}
+	p := currentPrincipal(c)
+	var out *models.LeaveRequest
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		out, err = transitionLeave(tx, row.ID, leaveActRevoke, p, leaveChange{Reason: req.Reason})
+		return err
+	})
+	if err != nil {
+		return leaveTransitionFailed(c, err)
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+/* ====================== Parent ====================== */
+
+// PUT /parent/leave-requests/:id
+// body: { type, reason, date_from, date_to } — แก้ได้เฉพาะตอนรออนุมัติ (ตรวจเหมือนตอนยื่น)
+func (h *ParentLeaveHandler) Amend(c echo.Context) error {
+	p := currentPrincipal(c)
+	if !p.IsParent() {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
+	}
+	row, err := h.find(c, p.ParentID)
+	if row == nil {
+		return err
+	}
+	var req parentLeaveReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	fields, _ := validateLeave(&req)
+	if len(fields) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "VALIDATION_ERROR", "fields": fields})
+	}
+
+	var out *models.LeaveRequest
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		out, err = transitionLeave(tx, row.ID, leaveActAmend, p, leaveChange{
+			Check: func(cur *models.LeaveRequest) error {
+				if err := requireSubmitter(p)(cur); err != nil {
+					return err
+				}
+				id, err := leaveOverlap(tx, cur.StudentID, cur.ID, req.DateFrom, req.DateTo)
+				if err != nil {
+					return err
+				}
+				if id != 0 {
+					return &errLeaveCode{http.StatusConflict, "LEAVE_OVERLAP"}
+				}
+				return nil
+			},
+			Changes: leaveAmendChanges(row, req),
+			Updates: map[string]any{
+				"type": req.Type, "reason": req.Reason, "date_from": req.DateFrom, "date_to": req.DateTo,
+			},
+		})
+		return err
+	})
+	if err != nil {
+		return leaveTransitionFailed(c, err)
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+func leaveAmendChanges(row *models.LeaveRequest, req parentLeaveReq) map[string][2]string {
+	out := map[string][2]string{}
+	for k, v := range map[string][2]string{
+		"type":      {row.Type, req.Type},
+		"reason":    {row.Reason, req.Reason},
+		"date_from": {row.DateFrom, req.DateFrom},
+		"date_to":   {row.DateTo, req.DateTo},
+	} {
+		if v[0] != v[1] {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// POST /parent/leave-requests/:id/cancel
+// body: { reason } (optional) — ยกเลิกใบที่รออนุมัติ หรือที่อนุมัติแล้วแต่ยังไม่ถึงวันเริ่มลา
+func (h *ParentLeaveHandler) Cancel(c echo.Context) error {
+	p := currentPrincipal(c)
+	if !p.IsParent() {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
+	}
+	row, err := h.find(c, p.ParentID)
+	if row == nil {
+		return err
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	_ = c.Bind(&req)
+
+	now := time.Now()
+	var out *models.LeaveRequest
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		out, err = transitionLeave(tx, row.ID, leaveActCancel, p, leaveChange{
+			Reason:  strings.TrimSpace(req.Reason),
+			Check:   leaveCancelCheck(p, now),
+			Updates: map[string]any{"withdrawn_at": &now},
+		})
+		return err
+	})
+	if err != nil {
+		return leaveTransitionFailed(c, err)
+	}
+	return c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+func TestLeaveCanTransition(t *testing.T) {
+	statuses := []string{"", leavePending, leaveApproved, leaveRejected, leaveCancelled, leaveRevoked}
+	actions := []string{leaveActSubmit, leaveActAmend, leaveActApprove, leaveActReject, leaveActCancel, leaveActRevoke}
+
+	// สถานะ → action ที่ทำได้ (นอกนั้นต้องเป็น ILLEGAL_TRANSITION)
+	legal := map[string]map[string]string{
+		"":            {leaveActSubmit: leavePending},
+		leavePending:  {leaveActAmend: leavePending, leaveActApprove: leaveApproved, leaveActReject: leaveRejected, leaveActCancel: leaveCancelled},
+		leaveApproved: {leaveActCancel: leaveCancelled, leaveActRevoke: leaveRevoked},
+	}
+	for _, from := range statuses {
+		for _, action := range actions {
+			to, want := legal[from][action]
+			if got := leaveCanTransition(from, action); got != want {
+				t.Errorf("%q + %s: leaveCanTransition = %v, want %v", from, action, got, want)
+			}
+			if want && leaveTransitions[action].To != to {
+				t.Errorf("%q + %s: to = %q, want %q", from, action, leaveTransitions[action].To, to)
+			}
+		}
+	}
+	if leaveCanTransition(leavePending, "delete") {
+		t.Errorf("unknown action allowed")
+	}
+}
+
+func TestLeaveActionsFor(t *testing.T) {
+	tests := []struct {
+		status string
+		want   []string
+	}{
+		{leavePending, []string{leaveActAmend, leaveActApprove, leaveActReject, leaveActCancel}},
+		{leaveApproved, []string{leaveActCancel, leaveActRevoke}},
+		{leaveRejected, []string{}},
+		{leaveCancelled, []string{}},
+		{leaveRevoked, []string{}},
+		{"unknown", []string{}},
+	}
+	for _, tt := range tests {
+		if got := leaveActionsFor(tt.status); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("leaveActionsFor(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestLeaveCancelCheck(t *testing.T) {
+	parentID := uint(7)
+	other := uint(8)
+	p := &Principal{ParentID: parentID, Role: roleParent}
+	now := time.Date(2025, 6, 10, 9, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		status   string
+		from     string
+		owner    *uint
+		wantCode string // "" = ยกเลิกได้
+	}{
+		{"pending, already started", leavePending, "2025-06-09", &parentID, ""},
+		{"approved, starts tomorrow", leaveApproved, "2025-06-11", &parentID, ""},
+		{"approved, starts today", leaveApproved, "2025-06-10", &parentID, "LEAVE_ALREADY_STARTED"},
+		{"approved, started yesterday", leaveApproved, "2025-06-09", &parentID, "LEAVE_ALREADY_STARTED"},
+		{"other parent", leavePending, "2025-06-11", &other, "NOT_SUBMITTER"},
+		{"submitted by staff", leavePending, "2025-06-11", nil, "NOT_SUBMITTER"},
+	}
+	for _, tt := range tests {
+		row := &models.LeaveRequest{Status: tt.status, DateFrom: tt.from, DateTo: tt.from, ParentID: tt.owner}
+		err := leaveCancelCheck(p, now)(row)
+		var coded *errLeaveCode
+		switch {
+		case tt.wantCode == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.wantCode != "" && (!errors.As(err, &coded) || coded.Code != tt.wantCode):
+			t.Errorf("%s: err = %v, want %s", tt.name, err, tt.wantCode)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+// kind ของตัวนับใน login_throttles
+const (
+	throttleByUsername = "username"
+	throttleByIP       = "ip"
+	throttleByParent   = "parent"   // login ผู้ปกครอง ต่ออีเมล/เบอร์โทร (แยกจาก username ของ staff)
+	throttleResetKey   = "reset"    // ขอรีเซ็ตรหัสผ่าน ต่ออีเมล/username
+	throttleResetIP    = "reset_ip" // ขอรีเซ็ตรหัสผ่าน ต่อ IP
+)
+
+/* ====================== Policy ====================== */
+
+// loginPolicy กติกากันเดารหัสผ่าน: ผิดติดกันเกินกำหนด → ต้องรอนานขึ้นเรื่อย ๆ แล้วล็อกชั่วคราว
+type loginPolicy struct {
+	KeyKind         string        // kind ของตัวนับต่อบัญชี
+	IPKind          string        // kind ของตัวนับต่อ IP
+	MaxUserFailures int           // ผิดกี่ครั้งต่อ username แล้วล็อก
+	MaxIPFailures   int           // ผิดกี่ครั้งต่อ IP แล้วล็อก
+	Window          time.Duration // นับครั้งที่ผิดภายในช่วงนี้ (เกินแล้วเริ่มนับใหม่)
+	LockFor         time.Duration // ล็อกนานเท่าไร
+	FreeAttempts    int           // ผิดได้กี่ครั้งก่อนเริ่มหน่วงเวลา
+	BaseDelay       time.Duration // หน่วงครั้งแรก (ครั้งถัดไป x2)
+	MaxDelay        time.Duration
+}
+
+func loadLoginPolicy() loginPolicy {
+	return loginPolicy{
+		KeyKind:         throttleByUsername,
+		IPKind:          throttleByIP,
+		MaxUserFailures: atoiOr(os.Getenv("LOGIN_MAX_FAILURES"), 5),
+		MaxIPFailures:   atoiOr(os.Getenv("LOGIN_MAX_FAILURES_PER_IP"), 20),
+		Window:          time.Duration(atoiOr(os.Getenv("LOGIN_FAILURE_WINDOW_MINUTES"), 15)) * time.Minute,
+		LockFor:         time.Duration(atoiOr(os.Getenv("LOGIN_LOCK_MINUTES"), 15)) * time.Minute,
+		FreeAttempts:    2,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+	}
+}
+
+// login ผู้ปกครอง: กติกาเดียวกับ staff แต่ตัวนับต่อบัญชีแยก kind (อีเมล/เบอร์ซ้ำกับ username ได้)
+func loadParentLoginPolicy() loginPolicy {
+	pol := loadLoginPolicy()
+	pol.KeyKind = throttleByParent
+	return pol
+}
+
+// เวลาที่ต้องรอหลังผิดครั้งที่ failures (0 = ยังไม่ต้องรอ)
+func (pol loginPolicy) delayFor(failures int) time.Duration {
+	if failures <= pol.FreeAttempts {
+		return 0
+	}
+	d := pol.BaseDelay << uint(failures-pol.FreeAttempts-1)
+	if d <= 0 || d > pol.MaxDelay {
+		d = pol.MaxDelay
+	}
+	return d
+}
+
+// ยังต้องรออีกนานเท่าไรสำหรับ key นี้ (0 = login ได้)
+func (pol loginPolicy) waitFor(kind, value string, now time.Time) (time.Duration, bool) {
+	var t models.LoginThrottle
+	if err := database.DB.Where("kind = ? AND value = ?", kind, value).First(&t).Error; err != nil {
+		return 0, false
+	}
+	if t.LockedUntil != nil && now.Before(*t.LockedUntil) {
+		return t.LockedUntil.Sub(now), true
+	}
+	if t.LastFailure != nil && now.Sub(*t.LastFailure) < pol.Window {
+		if next := t.LastFailure.Add(pol.delayFor(t.Failures)); now.Before(next) {
+			return next.Sub(now), false
+		}
+	}
+	return 0, false
+}
+
+// เช็คก่อนตรวจรหัสผ่าน: ถ้ายังติดล็อก/ยังไม่ถึงเวลาลองใหม่ → 429 พร้อม Retry-After
+func (pol loginPolicy) check(c echo.Context, username string) error {
+	now := time.Now()
+	for _, k := range [][2]string{{pol.KeyKind, username}, {pol.IPKind, c.RealIP()}} {
+		wait, locked := pol.waitFor(k[0], k[1], now)
+		if wait <= 0 {
+			continue
+		}
+		secs := int(wait.Seconds() + 0.999)
+		c.Response().Header().Set("Retry-After", strconv.Itoa(secs))
+		code := "TOO_MANY_ATTEMPTS"
+		if locked {
+			code = "ACCOUNT_LOCKED"
+			pol.logAttempt(c, username, code)
+		}
+		return echo.NewHTTPError(http.StatusTooManyRequests, map[string]any{"error": code, "retry_after": secs})
+	}
+	return nil
+}
+
+// บันทึกการ login ผิด + เพิ่มตัวนับทั้งฝั่ง username และ IP
+func (pol loginPolicy) recordFailure(c echo.Context, username, reason string) {
+	pol.logAttempt(c, username, reason)
+	pol.bump(pol.KeyKind, username, pol.MaxUserFailures)
+	pol.bump(pol.IPKind, c.RealIP(), pol.MaxIPFailures)
+}
+
+// นับคำขอ (เช่น ลืมรหัสผ่าน) โดยไม่ลง login_attempts — ไม่ใช่การ login ผิด
+func (pol loginPolicy) recordRequest(c echo.Context, key string) {
+	pol.bump(pol.KeyKind, key, pol.MaxUserFailures)
+	pol.bump(pol.IPKind, c.RealIP(), pol.MaxIPFailures)
+}
+
+// login สำเร็จ → ล้างตัวนับของ username (ตัวนับ IP ไม่ล้าง กันคนมีบัญชีจริงมาล้างให้)
+func (pol loginPolicy) recordSuccess(username string) {
+	_ = database.DB.Model(&models.LoginThrottle{}).
+		Where("kind = ? AND value = ?", pol.KeyKind, username).
+		Updates(map[string]any{"failures": 0, "last_failure": nil, "locked_until": nil}).Error
+}
+
+func (pol loginPolicy) logAttempt(c echo.Context, username, reason string) {
+	_ = database.DB.Create(&models.LoginAttempt{
+		Username:  truncate(username, 120),
+		IP:        c.RealIP(),
+		UserAgent: truncate(c.Request().UserAgent(), 255),
+		Reason:    reason,
+	}).Error
+}
+
+// เพิ่มตัวนับแบบ atomic (INSERT … ON CONFLICT) — คำขอพร้อมกันหลายตัวนับครบทุกครั้ง ไม่ชน unique index
+func (pol loginPolicy) bump(kind, value string, max int) {
+	now := time.Now()
+	var failures int
+	if err := database.DB.Raw(`INSERT INTO login_throttles (kind, value, failures, last_failure, created_at, updated_at)
+		VALUES (?, ?, 1, ?, ?, ?)
+		ON CONFLICT (kind, value) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttles.last_failure IS NULL OR login_throttles.last_failure <= ? THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			last_failure = EXCLUDED.last_failure,
+			updated_at = EXCLUDED.updated_at
+		RETURNING failures`,
+		kind, truncate(value, 120), now, now, now, now.Add(-pol.Window)).
+		Scan(&failures).Error; err != nil {
+		return
+	}
+	if max > 0 && failures >= max {
+		_ = database.DB.Model(&models.LoginThrottle{}).
+			Where("kind = ? AND value = ?", kind, truncate(value, 120)).
+			Update("locked_until", now.Add(pol.LockFor)).Error
+	}
+}
+
+/* ====================== Admin endpoints ====================== */
+
+// GET /auth/lockouts?all=true
+// ค่าเริ่มต้นคืนเฉพาะที่ยังล็อกอยู่, all=true คืนทุกตัวนับที่ยังมีครั้งที่ผิดค้างอยู่
+func (h *AuthHandler) ListLockouts(c echo.Context) error {
+	tx := database.DB.Model(&models.LoginThrottle{})
+	if strings.EqualFold(c.QueryParam("all"), "true") {
+		tx = tx.Where("failures > 0 OR locked_until > ?", time.Now())
+	} else {
+		tx = tx.Where("locked_until > ?", time.Now())
+	}
+	if kind := strings.TrimSpace(c.QueryParam("kind")); kind != "" {
+		tx = tx.Where("kind = ?", kind)
+	}
+	var rows []models.LoginThrottle
+	if err := tx.Order("updated_at DESC").Find(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, rows)
+}
+
+// DELETE /auth/lockouts/:id — ปลดล็อก + ล้างตัวนับ
+func (h *AuthHandler) Unlock(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	res := database.DB.Model(&models.LoginThrottle{}).Where("id = ?", id).
+		Updates(map[string]any{"failures": 0, "last_failure": nil, "locked_until": nil})
+	if res.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	if res.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"ok": true})
+}
+
+// GET /auth/login-attempts?username=&ip=&limit=
+func (h *AuthHandler) ListLoginAttempts(c echo.Context) error {
+	limit := atoiOr(c.QueryParam("limit"), 100)
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+	tx := database.DB.Model(&models.LoginAttempt{})
+	if u := strings.TrimSpace(c.QueryParam("username")); u != "" {
+		tx = tx.Where("username = ?", u)
+	}
+	if ip := strings.TrimSpace(c.QueryParam("ip")); ip != "" {
+		tx = tx.Where("ip = ?", ip)
+	}
+	var rows []models.LoginAttempt
+	if err := tx.Order("id DESC").Limit(limit).Find(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, rows)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/* ====================== DTOs ====================== */
+
+type parentRegisterReq struct {
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	PdpaOK   bool   `json:"pdpa_ok"` // ต้องยอมรับนโยบายคุ้มครองข้อมูลส่วนบุคคลก่อนใช้งาน
+}
+
+type parentLoginReq struct {
+	Email    string `json:"email"` // ใช้อีเมลหรือเบอร์โทรอย่างใดอย่างหนึ่ง
+	Phone    string `json:"phone"`
+	Password string `json:"password"`
+	PdpaOK   bool   `json:"pdpa_ok"` // ส่งมาเมื่อบัญชีเดิมยังไม่เคยยอมรับ PDPA
+}
+
+/* ====================== Handlers ====================== */
+
+// POST /auth/parent/register
+// body: { email, phone, name, password, pdpa_ok }
+// สมัครเสร็จ login ให้ทันที (คืน token คู่เหมือน /auth/parent/login)
+func (h *AuthHandler) ParentRegister(c echo.Context) error {
+	var req parentRegisterReq
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	req.Phone = strings.TrimSpace(req.Phone)
+	req.Name = strings.Join(strings.Fields(req.Name), " ")
+
+	fields := map[string]string{}
+	if req.Email == "" || !tchReEmail.MatchString(req.Email) {
+		fields["email"] = "invalid"
+	}
+	if d := onlyDigits(req.Phone); req.Phone != "" && (len(d) < 9 || len(d) > 10) {
+		fields["phone"] = "invalid"
+	}
+	if req.Name == "" {
+		fields["name"] = "required"
+	}
+	if len(req.Password) < 8 {
+		fields["password"] = "min_length_8"
+	}
+	if len(fields) > 0 {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, map[string]any{"error": "VALIDATION_ERROR", "fields": fields})
+	}
+	if !req.PdpaOK {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, map[string]any{"error": "PDPA_REQUIRED"})
+	}
+
+	var cnt int64
+	if err := database.DB.Model(&models.Parent{}).Where("email = ?", req.Email).Count(&cnt).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	if cnt > 0 {
+		return echo.NewHTTPError(http.StatusConflict, map[string]any{"error": "EMAIL_TAKEN"})
+	}
+
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "HASH_ERROR"})
+	}
+	p := models.Parent{
+		Email:    req.Email,
+		Phone:    req.Phone,
+		Name:     req.Name,
+		Password: hash,
+		PdpaOK:   true,
+	}
+	if err := database.DB.Create(&p).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+
+	resp, err := h.startSession(c, subjectForParent(&p))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "TOKEN_GEN_FAILED"})
+	}
+	return c.JSON(http.StatusCreated, resp)
+}
+
+// POST /auth/parent/login
+// body: { email | phone, password, pdpa_ok? }
+func (h *AuthHandler) ParentLogin(c echo.Context) error {
+	var req parentLoginReq
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	phone := strings.TrimSpace(req.Phone)
+	if (email == "" && phone == "") || req.Password == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "MISSING_FIELDS"})
+	}
+
+	login := email
+	if login == "" {
+		login = phone
+	}
+	if err := h.ParentThrottle.check(c, login); err != nil {
+		return err
+	}
+
+	var p models.Parent
+	q := database.DB.Model(&models.Parent{})
+	if email != "" {
+		q = q.Where("email = ?", email)
+	} else {
+		q = q.Where("phone = ?", phone)
+	}
+	if err := q.First(&p).Error; err != nil {
+		h.ParentThrottle.recordFailure(c, login, "INVALID_CREDENTIALS")
+		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_CREDENTIALS"})
+	}
+	if bcrypt.CompareHashAndPassword([]byte(p.Password), []byte(req.Password)) != nil {
+		h.ParentThrottle.recordFailure(c, login, "INVALID_CREDENTIALS")
+		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_CREDENTIALS"})
+	}
+	h.ParentThrottle.recordSuccess(login)
+
+	// บัญชีที่ยังไม่ยอมรับ PDPA ต้องยอมรับก่อน (ส่ง pdpa_ok=true มาพร้อม login)
+	if !p.PdpaOK {
+		if !req.PdpaOK {
+			return echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "PDPA_REQUIRED"})
+		}
+		if err := database.DB.Model(&p).Update("pdpa_ok", true).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+		}
+	}
+
+	resp, err := h.startSession(c, subjectForParent(&p))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "TOKEN_GEN_FAILED"})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+// สถานะใบลา
+const (
+	leavePending   = "รออนุมัติ"
+	leaveApproved  = "อนุมัติ"
+	leaveRejected  = "ปฏิเสธ"
+	leaveCancelled = "ยกเลิก"
+	leaveRevoked   = "เพิกถอน"
+)
+
+// ประเภทใบลาที่รับ (ค่าเดียวกับที่ FE ครูใช้กรอง) + คำพ้องจากแอพผู้ปกครอง
+var leaveTypes = map[string]string{
+	"ป่วย": "ป่วย", "ลาป่วย": "ป่วย",
+	"ธุระส่วนตัว": "ธุระส่วนตัว", "ลากิจ": "ธุระส่วนตัว",
+	"อื่นๆ": "อื่นๆ",
+}
+
+type ParentLeaveHandler struct{}
+
+func NewParentLeaveHandler() *ParentLeaveHandler { return &ParentLeaveHandler{} }
+
+type parentLeaveReq struct {
+	Type     string `json:"type"`
+	Reason   string `json:"reason"`
+	DateFrom string `json:"date_from"`
+	DateTo   string `json:"date_to"`
+}
+
+type parentLeaveRow struct {
+	models.LeaveRequest
+	StudentCode string `json:"student_code"`
+	StudentName string `json:"student_name"`
+}
+
+/* -------------------- Helpers -------------------- */
+
+// ตรวจข้อมูลใบลา: ประเภท, วันที่, เริ่ม/สิ้นสุดต้องเป็นวันเรียน → คืนจำนวนวันเรียนในช่วง
+func validateLeave(req *parentLeaveReq) (map[string]string, int) {
+	req.Type = strings.TrimSpace(req.Type)
+	req.Reason = strings.TrimSpace(req.Reason)
+	req.DateFrom = strings.TrimSpace(req.DateFrom)
+	req.DateTo = strings.TrimSpace(req.DateTo)
+
+	fields := map[string]string{}
+	if t, ok := leaveTypes[req.Type]; ok {
+		req.Type = t
+	} else {
+		fields["type"] = "invalid"
+	}
+	if req.Type == "อื่นๆ" && req.Reason == "" {
+		fields["reason"] = "required"
+	}
+	if !isDateYYYYMMDD(req.DateFrom) {
+		fields["date_from"] = "invalid"
+	}
+	if !isDateYYYYMMDD(req.DateTo) {
+		fields["date_to"] = "invalid"
+	}
+	if len(fields) > 0 {
+		return fields, 0
+	}
+	if req.DateFrom > req.DateTo {
+		fields["date_to"] = "before_date_from"
+		return fields, 0
+	}
+
+	// วันหยุดระหว่างช่วงไม่เป็นไร แต่วันเริ่ม/วันสิ้นสุดต้องเป็นวันเรียน
+	cal := loadSchoolCalendar(req.DateFrom, req.DateTo)
+	if info := cal.day(req.DateFrom); !info.SchoolDay {
+		fields["date_from"] = "not_school_day:" + info.Reason
+	}
+	if info := cal.day(req.DateTo); !info.SchoolDay {
+		fields["date_to"] = "not_school_day:" + info.Reason
+	}
+	if len(fields) > 0 {
+		return fields, 0
+	}
+	return nil, len(cal.schoolDays(req.DateFrom, req.DateTo))
+}
+
+// ใบลาของลูกที่ผู้ปกครองคนนี้ผูกไว้ (ไม่ใช่ลูก → ไม่พบ)
+func (h *ParentLeaveHandler) find(c echo.Context, parentID uint) (*models.LeaveRequest, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return nil, c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	var row models.LeaveRequest
+	if err := database.DB.First(&row, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+		}
+		return nil, c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	if !parentHasChild(parentID, row.StudentID) {
+		return nil, c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+	}
+	return &row, nil
+}
+
+var errLeaveOverlap = errors.New("leave overlaps an active request")
+
+// ใบลาที่ยังมีผล (รออนุมัติ/อนุมัติ) ของนักเรียนคนเดียวกันที่ช่วงวันที่ซ้อนกัน (0 = ไม่มี; exceptID = ใบที่กำลังแก้)
+// ล็อกแถวนักเรียนก่อน → ยื่น/แก้พร้อมกันของเด็กคนเดียวกันต้องรอกัน ไม่หลุดการตรวจทั้งคู่
+func leaveOverlap(tx *gorm.DB, studentID, exceptID uint, from, to string) (uint, error) {
+	var stu models.Student
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&stu, studentID).Error; err != nil {
+		return 0, err
+	}
+	var ids []uint
+	if err := tx.Model(&models.LeaveRequest{}).
+		Where("id <> ? AND student_id = ? AND status IN ? AND date_from <= ? AND date_to >= ?",
+			exceptID, studentID, []string{leavePending, leaveApproved}, to, from).
+		Order("id").Limit(1).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return ids[0], nil
+}
+
+/* -------------------- Handlers -------------------- */
+
+// POST /parent/children/:id/leave-requests
+// body: { type, reason, date_from, date_to }
+func (h *ParentLeaveHandler) Submit(c echo.Context) error {
+	p := currentPrincipal(c)
+	if !p.IsParent() {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
+	}
+	sid, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || sid == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	if !parentHasChild(p.ParentID, uint(sid)) {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "STUDENT_NOT_FOUND"})
+	}
+
+	var req parentLeaveReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	fields, days := validateLeave(&req)
+	if len(fields) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "VALIDATION_ERROR", "fields": fields})
+	}
+
+	parentID := p.ParentID
+	row := models.LeaveRequest{
+		StudentID: uint(sid),
+		Type:      req.Type,
+		Reason:    req.Reason,
+		DateFrom:  req.DateFrom,
+		DateTo:    req.DateTo,
+		Status:    leavePending,
+		ParentID:  &parentID,
+	}
+	// ตรวจซ้อน + สร้าง + ส่งถึงครูประจำชั้นหลักในคราวเดียว
+	var overlapID uint
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		id, err := leaveOverlap(tx, uint(sid), 0, req.DateFrom, req.DateTo)
+		if err != nil {
+			return err
+		}
+		if id != 0 {
+			overlapID = id
+			return errLeaveOverlap
+		}
+		if err := tx.Create(&row).Error; err != nil {
+			return err
+		}
+		if err := recordLeaveTransition(tx, row.ID, leaveActSubmit, "", leavePending, p, leaveChange{}); err != nil {
+			return err
+		}
+		return routeLeave(tx, &row, approverMain, routeSubmitted, time.Now())
+	})
+	if err == errLeaveOverlap {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "LEAVE_OVERLAP", "leave_request_id": overlapID})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusCreated, map[string]any{"leave_request": row, "school_days": days})
+}
+
+// GET /parent/leave-requests?studentId=&status=&page=&size=
+// ใบลาของลูกทุกคนที่ผูกไว้ (รวมที่ผู้ปกครองอีกคนยื่น)
+func (h *ParentLeaveHandler) List(c echo.Context) error {
+	p := currentPrincipal(c)
+	if !p.IsParent() {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
+	}
+	page := atoiOr(c.QueryParam("page"), 1)
+	size := atoiOr(c.QueryParam("size"), 20)
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+
+	tx := database.DB.Table("leave_requests AS l").
+		Joins("JOIN students s ON s.id = l.student_id").
+		Joins("JOIN parent_students ps ON ps.student_id = l.student_id AND ps.parent_id = ? AND ps.status = ?", p.ParentID, linkApproved)
+	if sid := strings.TrimSpace(c.QueryParam("studentId")); sid != "" {
+		tx = tx.Where("l.student_id = ?", sid)
+	}
+	if status := strings.TrimSpace(c.QueryParam("status")); status != "" {
+		tx = tx.Where("l.status = ?", status)
+	}
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_COUNT_FAILED"})
+	}
+	rows := []parentLeaveRow{}
+	if err := tx.Select(`l.*, s.student_id AS student_code,
+			TRIM(CONCAT(s.prefix, s.first_name, ' ', s.last_name)) AS student_name`).
+		Order("l.submitted_at DESC, l.id DESC").
+		Offset((page - 1) * size).Limit(size).
+		Scan(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": rows, "page": page, "size": size, "total": total})
+}
+
+// GET /parent/leave-requests/:id — ใบลา + ประวัติ
+func (h *ParentLeaveHandler) Get(c echo.Context) error {
+	p := currentPrincipal(c)
+	if !p.IsParent() {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
+	}
+	row, err := h.find(c, p.ParentID)
+	if row == nil {
+		return err
+	}
+	out, err := leaveDetail(row)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+// สถานะคำขอผูกผู้ปกครอง-นักเรียน (ใช้คำเดียวกับใบลา)
+const (
+	linkPending  = "รออนุมัติ"
+	linkApproved = "อนุมัติ"
+	linkRejected = "ปฏิเสธ"
+	linkRevoked  = "เพิกถอน" // เคยอนุมัติแล้วถูกถอนสิทธิ์
+)
+
+type ParentLinkHandler struct{}
+
+func NewParentLinkHandler() *ParentLinkHandler { return &ParentLinkHandler{} }
+
+type parentLinkReq struct {
+	StudentCode string `json:"student_code"` // students.student_id (รหัสนักเรียน)
+	NationalID  string `json:"national_id"`  // ใช้ยืนยันตัวตนเด็ก
+	Relation    string `json:"relation"`     // บิดา/มารดา/ผู้ปกครอง
+}
+
+type linkDecisionReq struct {
+	RejectReason string `json:"rejectReason"`
+}
+
+type linkRevokeReq struct {
+	Reason string `json:"reason"`
+}
+
+var errLinkStatus = errors.New("parent link status does not allow this decision")
+
+/* -------------------- Helpers -------------------- */
+
+// staff คนนี้อนุมัติคำขอของนักเรียนคนนี้ได้ไหม (สิทธิ์ classes.all ได้ทุกคน, นอกนั้นเฉพาะห้องที่ประจำชั้น)
+func canDecideLink(p *Principal, stu *models.Student) bool {
+	return scopeOf(p).allows(stu.Grade, stu.Room)
+}
+
+// ผู้ปกครองคนนี้ผูกกับนักเรียนคนนี้และอนุมัติแล้วหรือยัง
+func parentHasChild(parentID, studentID uint) bool {
+	var n int64
+	if err := database.DB.Model(&models.ParentStudent{}).
+		Where("parent_id = ? AND student_id = ? AND status = ?", parentID, studentID, linkApproved).
+		Count(&n).Error; err != nil {
+		return false
+	}
+	return n > 0
+}
+
+/* -------------------- Parent side -------------------- */
+
+// POST /parent/children
+// body: { student_code, national_id, relation } → สร้างคำขอผูกบัญชี (รออนุมัติ)
+func (h *ParentLinkHandler) RequestLink(c echo.Context) error {
+	p := currentPrincipal(c)
+	if !p.IsParent() {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
+	}
+	parentID := p.ParentID
+
+	var req parentLinkReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	req.StudentCode = strings.TrimSpace(req.StudentCode)
+	req.NationalID = strings.TrimSpace(req.NationalID)
+	req.Relation = strings.TrimSpace(req.Relation)
+	if req.StudentCode == "" || req.NationalID == "" {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+			"error":  "VALIDATION_ERROR",
+			"fields": map[string]string{"student_code": "required", "national_id": "required"},
+		})
+	}
+
+	// ต้องรู้ทั้งรหัสนักเรียนและเลขบัตรถึงจะขอผูกได้ (ไม่บอกว่าผิดที่ช่องไหน)
+	var stu models.Student
+	if err := database.DB.
+		Where("student_id = ? AND national_id = ?", req.StudentCode, req.NationalID).
+		First(&stu).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "STUDENT_NOT_FOUND"})
+	}
+
+	var link models.ParentStudent
+	err := database.DB.Where("parent_id = ? AND student_id = ?", parentID, stu.ID).First(&link).Error
+	switch {
+	case err == nil && (link.Status == linkRejected || link.Status == linkRevoked):
+		// เคยถูกปฏิเสธ/ถอนสิทธิ์ → ยื่นใหม่ได้ (รออนุมัติอีกครั้ง)
+		if err := database.DB.Model(&link).Updates(map[string]any{
+			"status":        linkPending,
+			"relation":      req.Relation,
+			"decided_at":    nil,
+			"decided_by":    nil,
+			"reject_reason": "",
+		}).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+		}
+	case err == nil:
+		return c.JSON(http.StatusConflict, map[string]any{"error": "LINK_EXISTS", "status": link.Status})
+	case err == gorm.ErrRecordNotFound:
+		link = models.ParentStudent{
+			ParentID:  parentID,
+			StudentID: stu.ID,
+			Relation:  req.Relation,
+			Status:    linkPending,
+		}
+		if err := database.DB.Create(&link).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+		}
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]any{
+		"id":         link.ID,
+		"student_id": stu.ID,
+		"status":     linkPending,
+	})
+}
+
+/* -------------------- Staff side -------------------- */
+
+// GET /parent-links?status=&page=&size=
+// สิทธิ์ classes.all เห็นทั้งหมด, นอกนั้นเห็นเฉพาะนักเรียนในห้องที่ตัวเองประจำชั้น
+func (h *ParentLinkHandler) List(c echo.Context) error {
+	p := currentPrincipal(c)
+
+	page := atoiOr(c.QueryParam("page"), 1)
+	size := atoiOr(c.QueryParam("size"), 20)
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+
+	type row struct {
+		ID          uint       `json:"id"`
+		ParentID    uint       `json:"parent_id"`
+		ParentName  string     `json:"parent_name"`
+		ParentPhone string     `json:"parent_phone"`
+		StudentID   uint       `json:"student_id"`
+		StudentCode string     `json:"student_code"`
+		StudentName string     `json:"student_name"`
+		Grade       string     `json:"grade"`
+		Room        string     `json:"room"`
+		Relation    string     `json:"relation"`
+		Status      string     `json:"status"`
+		DecidedAt   *time.Time `json:"decided_at"`
+		CreatedAt   time.Time  `json:"created_at"`
+	}
+
+	tx := database.DB.Table("parent_students AS ps").
+		Joins("JOIN students s ON s.id = ps.student_id").
+		Joins("JOIN parents p ON p.id = ps.parent_id")
+	if status := strings.TrimSpace(c.QueryParam("status")); status != "" {
+		tx = tx.Where("ps.status = ?", status)
+	}
+	tx = scopeOf(p).where(tx, "s.grade", "s.room")
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_COUNT_FAILED"})
+	}
+
+	var rows []row
+	if err := tx.Select(`ps.id, ps.parent_id, p.name AS parent_name, p.phone AS parent_phone,
+			ps.student_id, s.student_id AS student_code,
+			TRIM(CONCAT(s.prefix, s.first_name, ' ', s.last_name)) AS student_name,
+			s.grade, s.room, ps.relation, ps.status, ps.decided_at, ps.created_at`).
+		Order("ps.created_at DESC, ps.id DESC").
+		Offset((page - 1) * size).Limit(size).
+		Scan(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": rows, "page": page, "size": size, "total": total})
+}
+
+// POST /parent-links/:id/approve
+func (h *ParentLinkHandler) Approve(c echo.Context) error {
+	return h.decide(c, linkPending, linkApproved, "", "ALREADY_DECIDED")
+}
+
+// POST /parent-links/:id/reject
+// body: { rejectReason }
+func (h *ParentLinkHandler) Reject(c echo.Context) error {
+	var body linkDecisionReq
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	if strings.TrimSpace(body.RejectReason) == "" {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "REJECT_REASON_REQUIRED"})
+	}
+	return h.decide(c, linkPending, linkRejected, strings.TrimSpace(body.RejectReason), "ALREADY_DECIDED")
+}
+
+// POST /parent-links/:id/revoke
+// body: { reason } — ถอนสิทธิ์ที่อนุมัติไปแล้ว (ผู้ปกครองต้องยื่นขอใหม่)
+func (h *ParentLinkHandler) Revoke(c echo.Context) error {
+	var body linkRevokeReq
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	if strings.TrimSpace(body.Reason) == "" {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "REASON_REQUIRED"})
+	}
+	return h.decide(c, linkApproved, linkRevoked, strings.TrimSpace(body.Reason), "LINK_NOT_APPROVED")
+}
+
+// เปลี่ยนสถานะ from → to (ล็อกแถวก่อนตรวจ กันสองคนตัดสินพร้อมกัน); สถานะไม่ใช่ from → 409 conflictCode
+func (h *ParentLinkHandler) decide(c echo.Context, from, to, reason, conflictCode string) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	p := currentPrincipal(c)
+
+	var link models.ParentStudent
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&link, id).Error; err != nil {
+			return err
+		}
+		var stu models.Student
+		if err := tx.First(&stu, link.StudentID).Error; err != nil {
+			return err
+		}
+		if !canDecideLink(p, &stu) {
+			// ไม่ใช่ห้องที่ดูแล → ทำเหมือนไม่มีรายการนี้
+			return gorm.ErrRecordNotFound
+		}
+		if link.Status != from {
+			return errLinkStatus
+		}
+
+		now := time.Now()
+		updates := map[string]any{
+			"status":        to,
+			"decided_at":    &now,
+			"reject_reason": reason,
+		}
+		if p.UserID > 0 {
+			updates["decided_by"] = p.UserID
+		}
+		return tx.Model(&link).Updates(updates).Error
+	})
+	switch {
+	case err == nil:
+		return c.JSON(http.StatusOK, map[string]any{"ok": true, "status": to})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+	case errors.Is(err, errLinkStatus):
+		return c.JSON(http.StatusConflict, map[string]any{"error": conflictCode, "status": link.Status})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+}
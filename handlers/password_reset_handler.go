@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/mailer"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/* ====================== Policy ====================== */
+
+// จำกัดคำขอลืมรหัสผ่าน (ใช้ตัวนับเดียวกับ login แต่คนละ kind, ไม่หน่วงเวลา ล็อกอย่างเดียว)
+func loadResetPolicy() loginPolicy {
+	return loginPolicy{
+		KeyKind:         throttleResetKey,
+		IPKind:          throttleResetIP,
+		MaxUserFailures: atoiOr(os.Getenv("PASSWORD_RESET_MAX_PER_ACCOUNT"), 3),
+		MaxIPFailures:   atoiOr(os.Getenv("PASSWORD_RESET_MAX_PER_IP"), 10),
+		Window:          time.Hour,
+		LockFor:         time.Hour,
+		FreeAttempts:    1 << 30,
+	}
+}
+
+/* ====================== DTOs ====================== */
+
+type forgotPasswordReq struct {
+	Email    string `json:"email"`    // staff หรือผู้ปกครอง
+	Username string `json:"username"` // staff เท่านั้น (ส่งไปอีเมลของบัญชี/ครู)
+}
+
+type resetPasswordWithTokenReq struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+/* ====================== Helpers ====================== */
+
+// เจ้าของ token รีเซ็ต + อีเมลที่จะส่งไป
+type resetTarget struct {
+	UserID   uint
+	ParentID uint
+	Name     string
+	Email    string
+}
+
+// อีเมลของบัญชี staff: users.email ก่อน ไม่มีค่อยใช้อีเมลในข้อมูลครู
+func staffEmail(u *models.User) string {
+	if e := strings.TrimSpace(u.Email); e != "" {
+		return e
+	}
+	if u.TeacherID != nil {
+		var t models.Teacher
+		if err := database.DB.Select("email").First(&t, *u.TeacherID).Error; err == nil {
+			return strings.TrimSpace(t.Email)
+		}
+	}
+	return ""
+}
+
+func findResetTargets(email, username string) []resetTarget {
+	var out []resetTarget
+	addUser := func(u *models.User) {
+		if !u.Enabled {
+			return
+		}
+		if e := staffEmail(u); e != "" {
+			out = append(out, resetTarget{UserID: u.ID, Name: u.Username, Email: e})
+		}
+	}
+
+	if username != "" {
+		var u models.User
+		if err := database.DB.Where("username = ?", username).First(&u).Error; err == nil {
+			addUser(&u)
+		}
+		return out
+	}
+
+	var u models.User
+	if err := database.DB.Where("LOWER(email) = ?", email).First(&u).Error; err == nil {
+		addUser(&u)
+	} else {
+		var t models.Teacher
+		if err := database.DB.Select("id").Where("LOWER(email) = ?", email).First(&t).Error; err == nil {
+			if err := database.DB.Where("teacher_id = ?", t.ID).First(&u).Error; err == nil {
+				addUser(&u)
+			}
+		}
+	}
+	var p models.Parent
+	if err := database.DB.Where("email = ?", email).First(&p).Error; err == nil {
+		out = append(out, resetTarget{ParentID: p.ID, Name: p.Name, Email: p.Email})
+	}
+	return out
+}
+
+// ออก token ใหม่ (token เดิมที่ยังไม่ใช้ของบัญชีเดียวกันใช้ไม่ได้อีก)
+func (h *AuthHandler) issueResetToken(c echo.Context, t resetTarget) (string, error) {
+	raw, err := newOpaqueToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND parent_id = ? AND used_at IS NULL", t.UserID, t.ParentID).
+			Update("used_at", &now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    t.UserID,
+			ParentID:  t.ParentID,
+			TokenHash: hashToken(raw),
+			ExpiresAt: now.Add(h.ResetTTL),
+			IP:        c.RealIP(),
+		}).Error
+	})
+	return raw, err
+}
+
+func resetLink(token string) string {
+	base := os.Getenv("PASSWORD_RESET_URL")
+	if base == "" {
+		base = "http://localhost:3000/reset-password"
+	}
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + url.QueryEscape(token)
+}
+
+func resetMessage(t resetTarget, link string, ttl time.Duration) mailer.Message {
+	return mailer.Message{
+		To:      t.Email,
+		Subject: "ตั้งรหัสผ่านใหม่",
+		Body: fmt.Sprintf(`เรียน %s
+
+มีคำขอตั้งรหัสผ่านใหม่สำหรับบัญชีของคุณ กดลิงก์ด้านล่างเพื่อตั้งรหัสผ่านใหม่
+(ลิงก์ใช้ได้ครั้งเดียว ภายใน %d นาที)
+
+%s
+
+ถ้าคุณไม่ได้ขอ ไม่ต้องทำอะไร รหัสผ่านเดิมยังใช้ได้ตามปกติ
+`, t.Name, int(ttl.Minutes()), link),
+	}
+}
+
+/* ====================== Handlers ====================== */
+
+// POST /auth/forgot
+// body: { email } หรือ { username }
+// ตอบ 202 เหมือนกันทุกกรณี (ไม่บอกว่ามีบัญชีนี้หรือไม่) ส่งอีเมลเบื้องหลัง
+func (h *AuthHandler) ForgotPassword(c echo.Context) error {
+	var req forgotPasswordReq
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	username := strings.TrimSpace(req.Username)
+	key := email
+	if key == "" {
+		key = username
+	}
+	if key == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "MISSING_FIELDS"})
+	}
+
+	if err := h.Reset.check(c, key); err != nil {
+		return err
+	}
+	// นับทุกคำขอ ไม่ว่าจะมีบัญชีหรือไม่ (กันใช้เส้นนี้ไล่หาอีเมล/ยิงอีเมลใส่คนอื่น)
+	h.Reset.recordRequest(c, key)
+
+	for _, t := range findResetTargets(email, username) {
+		raw, err := h.issueResetToken(c, t)
+		if err != nil {
+			log.Printf("[auth] warn: issue reset token failed: %v", err)
+			continue
+		}
+		msg := resetMessage(t, resetLink(raw), h.ResetTTL)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := h.Mailer.Send(ctx, msg); err != nil {
+				log.Printf("[auth] warn: send reset mail to %s failed: %v", msg.To, err)
+			}
+		}()
+	}
+	return c.JSON(http.StatusAccepted, map[string]any{"ok": true})
+}
+
+// POST /auth/reset
+// body: { token, password }
+// ตั้งรหัสใหม่ + ตัดทุก session ของบัญชี (2FA ยังต้องใช้ตอน login ตามเดิม)
+func (h *AuthHandler) ResetPasswordWithToken(c echo.Context) error {
+	var req resetPasswordWithTokenReq
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	raw := strings.TrimSpace(req.Token)
+	if raw == "" || req.Password == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "MISSING_FIELDS"})
+	}
+	if len(req.Password) < 8 {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, map[string]any{
+			"error":  "VALIDATION_ERROR",
+			"fields": map[string]string{"password": "min_length_8"},
+		})
+	}
+
+	var rt models.PasswordResetToken
+	if err := database.DB.Where("token_hash = ?", hashToken(raw)).First(&rt).Error; err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "INVALID_RESET_TOKEN"})
+	}
+	if rt.UsedAt != nil || time.Now().After(rt.ExpiresAt) {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "RESET_TOKEN_EXPIRED"})
+	}
+
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "HASH_ERROR"})
+	}
+
+	var (
+		username   string   // staff
+		parentKeys []string // ผู้ปกครอง (login ด้วยอีเมลหรือเบอร์โทร)
+	)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// ใช้ได้ครั้งเดียว: update เฉพาะแถวที่ยังไม่ถูกใช้
+		res := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", rt.ID).
+			Update("used_at", &now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errResetTokenUsed
+		}
+
+		if rt.ParentID != 0 {
+			if err := tx.Model(&models.Parent{}).Where("id = ?", rt.ParentID).
+				Update("password", hash).Error; err != nil {
+				return err
+			}
+			var p models.Parent
+			if err := tx.Select("email", "phone").First(&p, rt.ParentID).Error; err == nil {
+				parentKeys = []string{strings.ToLower(strings.TrimSpace(p.Email)), strings.TrimSpace(p.Phone)}
+			}
+			return revokeAllParentSessions(tx, rt.ParentID)
+		}
+
+		var u models.User
+		if err := tx.First(&u, rt.UserID).Error; err != nil {
+			return err
+		}
+		if !u.Enabled {
+			return errResetAccountDisabled
+		}
+		if err := tx.Model(&u).Updates(map[string]any{
+			"password_hash":         hash,
+			"force_password_change": false,
+			"last_password_change":  &now,
+		}).Error; err != nil {
+			return err
+		}
+		username = u.Username
+		return revokeAllSessions(tx, u.ID)
+	})
+	switch err {
+	case nil:
+	case errResetTokenUsed:
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "RESET_TOKEN_EXPIRED"})
+	case errResetAccountDisabled:
+		return echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "ACCOUNT_DISABLED"})
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+
+	// ตั้งรหัสใหม่แล้ว → ปลดล็อก login ของบัญชีนี้
+	if username != "" {
+		h.Login.recordSuccess(username)
+	}
+	for _, k := range parentKeys {
+		if k != "" {
+			h.ParentThrottle.recordSuccess(k)
+		}
+	}
+	return c.JSON(http.StatusOK, map[string]any{"ok": true, "relogin_required": true})
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+// ===== Permission catalogue =====
+// route ประกาศสิทธิ์ที่ต้องใช้ใน routes.RegisterRoutes ด้วย auth.RequirePermission(...)
+const (
+	PermSchoolManage    = "school.manage"       // ตั้งค่าข้อมูลโรงเรียน
+	PermTeachersRead    = "teachers.read"       // ดูรายชื่อครู
+	PermStudentsRead    = "students.read"       // ดูรายชื่อนักเรียน
+	PermStudentsManage  = "students.manage"     // เพิ่ม/แก้/ย้ายนักเรียน
+	PermHomeroomsRead   = "homerooms.read"      // ดูครูประจำชั้น
+	PermCalendarRead    = "calendar.read"       // ดูปฏิทินการศึกษา
+	PermCalendarManage  = "calendar.manage"     // แก้ปฏิทินการศึกษา
+	PermAttendanceRead  = "attendance.read"     // ดูการเข้าเรียน
+	PermAttendanceMark  = "attendance.mark"     // เช็คชื่อ
+	PermAttendanceAdmin = "attendance.admin"    // สั่งเติม "ขาด" ย้อนหลัง, อนุมัติการแก้ข้อมูลเก่า
+	PermLeaveRead       = "leave.read"          // ดูใบลา
+	PermLeaveApprove    = "leave.approve"       // อนุมัติ/ปฏิเสธใบลา (เฉพาะใบที่ส่งถึงตัวเอง)
+	PermLeaveAdmin      = "leave.admin"         // อนุมัติใบลาที่ส่งต่อถึง admin / ตัดสินแทนครูได้ทุกใบ
+	PermParentLinks     = "parent_links.manage" // อนุมัติคำขอผูกผู้ปกครอง-นักเรียน
+	PermDashboardRead   = "dashboard.read"      // ดู dashboard
+	PermAllClasses      = "classes.all"         // เห็นทุกห้อง (ไม่มี = เฉพาะห้องที่ตัวเองเป็นครูประจำชั้น)
+	PermAccountsManage  = "accounts.manage"     // จัดการบัญชีครู (สร้าง/รีเซ็ตรหัส/ปิดบัญชี/reset 2FA)
+	PermSecurityManage  = "security.manage"     // ล็อก login, กุญแจ JWT, นโยบาย 2FA
+	PermRolesManage     = "roles.manage"        // จัดการ role และกำหนด role ให้ผู้ใช้
+	PermGateManage      = "gate.manage"         // เครื่องสแกนหน้าประตู + บัตรนักเรียน
+)
+
+type permissionInfo struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+}
+
+// ลำดับเดียวกับที่แสดงในหน้าจัดการ role
+var permissionCatalogue = []permissionInfo{
+	{PermSchoolManage, "ตั้งค่าข้อมูลโรงเรียน"},
+	{PermTeachersRead, "ดูรายชื่อครู"},
+	{PermStudentsRead, "ดูรายชื่อนักเรียน"},
+	{PermStudentsManage, "เพิ่ม/แก้ไข/ย้ายนักเรียน"},
+	{PermHomeroomsRead, "ดูครูประจำชั้น"},
+	{PermCalendarRead, "ดูปฏิทินการศึกษา"},
+	{PermCalendarManage, "แก้ไขปฏิทินการศึกษา"},
+	{PermAttendanceRead, "ดูการเข้าเรียน"},
+	{PermAttendanceMark, "เช็คชื่อนักเรียน"},
+	{PermAttendanceAdmin, "จัดการข้อมูลการเข้าเรียน (เติมขาดอัตโนมัติ/อนุมัติการแก้ไขย้อนหลัง)"},
+	{PermLeaveRead, "ดูใบลา"},
+	{PermLeaveApprove, "อนุมัติ/ปฏิเสธใบลา"},
+	{PermLeaveAdmin, "อนุมัติใบลาที่ส่งต่อถึงผู้ดูแล/ตัดสินแทนครูประจำชั้น"},
+	{PermParentLinks, "อนุมัติคำขอผูกบัญชีผู้ปกครอง"},
+	{PermDashboardRead, "ดู dashboard"},
+	{PermAllClasses, "เห็นข้อมูลทุกห้อง (ไม่จำกัดเฉพาะห้องที่ประจำชั้น)"},
+	{PermAccountsManage, "จัดการบัญชีครู"},
+	{PermSecurityManage, "ตั้งค่าความปลอดภัยการเข้าสู่ระบบ"},
+	{PermRolesManage, "จัดการ role และสิทธิ์"},
+	{PermGateManage, "จัดการเครื่องสแกนหน้าประตูและบัตรนักเรียน"},
+}
+
+func isKnownPermission(p string) bool {
+	for _, x := range permissionCatalogue {
+		if x.Key == p {
+			return true
+		}
+	}
+	return false
+}
+
+// role ตั้งต้น
+const (
+	roleAdmin   = "admin"
+	roleTeacher = "teacher"
+)
+
+// สิทธิ์เริ่มต้นของครู (แก้ได้ภายหลังผ่าน /roles)
+var defaultTeacherPermissions = []string{
+	PermHomeroomsRead,
+	PermCalendarRead,
+	PermAttendanceRead,
+	PermAttendanceMark,
+	PermLeaveRead,
+	PermLeaveApprove,
+	PermParentLinks,
+	PermDashboardRead,
+}
+
+// สิทธิ์ทั้งหมดของ role (admin ได้ทุกข้อใน catalogue เสมอ รวมข้อที่เพิ่มมาใหม่)
+func permissionsForRole(role string) map[string]bool {
+	out := map[string]bool{}
+	if role == roleAdmin {
+		for _, p := range permissionCatalogue {
+			out[p.Key] = true
+		}
+		return out
+	}
+	var perms []string
+	if err := database.DB.Model(&models.RolePermission{}).
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name = ?", role).
+		Pluck("role_permissions.permission", &perms).Error; err != nil {
+		return out
+	}
+	for _, p := range perms {
+		out[p] = true
+	}
+	return out
+}
+
+// EnsureDefaultRoles สร้าง role admin/teacher ถ้ายังไม่มี (เรียกตอนเริ่มระบบ)
+func EnsureDefaultRoles() error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		defaults := []models.Role{
+			{Name: roleAdmin, DisplayName: "ผู้ดูแลระบบ", Description: "ทุกสิทธิ์", System: true},
+			{Name: roleTeacher, DisplayName: "ครู", Description: "ครูประจำชั้น", System: true},
+		}
+		for _, r := range defaults {
+			var cur models.Role
+			err := tx.Where("name = ?", r.Name).First(&cur).Error
+			if err == nil {
+				continue
+			}
+			if err != gorm.ErrRecordNotFound {
+				return err
+			}
+			if err := tx.Create(&r).Error; err != nil {
+				return err
+			}
+			if r.Name == roleTeacher {
+				if err := setRolePermissions(tx, r.ID, defaultTeacherPermissions); err != nil {
+					return err
+				}
+			}
+			log.Printf("[bootstrap] role created: %s", r.Name)
+		}
+		return nil
+	})
+}
+
+// แทนที่สิทธิ์ทั้งชุดของ role
+func setRolePermissions(tx *gorm.DB, roleID uint, perms []string) error {
+	if err := tx.Where("role_id = ?", roleID).Delete(&models.RolePermission{}).Error; err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	rows := make([]models.RolePermission, 0, len(perms))
+	for _, p := range perms {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		rows = append(rows, models.RolePermission{RoleID: roleID, Permission: p})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Create(&rows).Error
+}
+
+/* ====================== Middleware ====================== */
+
+// RequirePermission: ใช้ต่อท้ายจาก RequireAuth เพื่อบังคับสิทธิ์ตาม permission ของ role
+// ตัวอย่าง: secured.GET("/students", h.List, auth.RequirePermission(handlers.PermStudentsRead))
+func (h *AuthHandler) RequirePermission(perm string) echo.MiddlewareFunc {
+	if !isKnownPermission(perm) {
+		// พิมพ์ชื่อสิทธิ์ผิดใน routes → ให้รู้ตั้งแต่ตอนเริ่มระบบ
+		log.Fatalf("[auth] unknown permission %q", perm)
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p := currentPrincipal(c)
+			if p == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_TOKEN"})
+			}
+			if !p.Can(perm) {
+				return echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "FORBIDDEN", "permission": perm})
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireStaff: บัญชี users ทุก role (ไม่รวมผู้ปกครอง) เช่น ข้อมูลบัญชีตัวเอง
+func (h *AuthHandler) RequireStaff(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		p := currentPrincipal(c)
+		if p == nil || p.UserID == 0 {
+			return echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "FORBIDDEN"})
+		}
+		return next(c)
+	}
+}
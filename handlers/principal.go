@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+// key ใน echo.Context ที่ RequireAuth ใส่ Principal ไว้
+const principalKey = "auth.principal"
+
+// Principal = ผู้ที่ login อยู่ของ request นี้ (มีที่เดียว ทุก handler อ่านผ่าน currentPrincipal)
+type Principal struct {
+	UserID    uint   // users.id (0 ถ้าเป็นผู้ปกครอง)
+	ParentID  uint   // parents.id (0 ถ้าเป็น staff)
+	TeacherID uint   // teachers.id ของบัญชีครู (0 ถ้าไม่ได้ผูกกับครู)
+	Role      string // ชื่อ role (admin, teacher, ... ตาราง roles) หรือ parent
+	Name      string // username หรือชื่อผู้ปกครอง
+	SchoolID  uint   // schools.id (ระบบมีโรงเรียนเดียว → แถวแรก)
+	SessionID string // sid ของ refresh token family (ว่างถ้าเป็น token จำกัดสิทธิ์)
+	Scope     string // ว่าง = ปกติ, scopePasswordChange = เปลี่ยนรหัสได้อย่างเดียว
+
+	Permissions map[string]bool // สิทธิ์ของ role (ผู้ปกครองไม่มี)
+}
+
+func (p *Principal) IsParent() bool { return p != nil && p.Role == roleParent }
+
+// Can = role ของผู้ใช้มีสิทธิ์นี้ไหม
+func (p *Principal) Can(perm string) bool { return p != nil && p.Permissions[perm] }
+
+// currentPrincipal คืน nil ถ้า route นี้ไม่ได้ผ่าน RequireAuth
+func currentPrincipal(c echo.Context) *Principal {
+	p, _ := c.Get(principalKey).(*Principal)
+	return p
+}
+
+// id ของโรงเรียน (แถวแรกของ schools; 0 ถ้ายังไม่ได้ตั้งค่า)
+func currentSchoolID() uint {
+	var id uint
+	_ = database.DB.Model(&models.School{}).Select("id").Order("id asc").Limit(1).Scan(&id).Error
+	return id
+}
+
+// teacher id ของบัญชีครู: ใช้ users.teacher_id ก่อน ไม่มีค่อย map ด้วย username/phone แบบเดิม
+func teacherIDForUser(u *models.User) uint {
+	if u.TeacherID != nil {
+		return *u.TeacherID
+	}
+	if u.Role != roleTeacher {
+		return 0
+	}
+	if t, err := findTeacherForUser(u); err == nil && t != nil {
+		return t.ID
+	}
+	return 0
+}
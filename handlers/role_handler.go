@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+type RoleHandler struct{}
+
+func NewRoleHandler() *RoleHandler { return &RoleHandler{} }
+
+var reRoleName = regexp.MustCompile(`^[a-z][a-z0-9_]{1,19}$`)
+
+/* ====================== DTOs ====================== */
+
+type roleReq struct {
+	Name        string   `json:"name"` // ใช้ตอนสร้างเท่านั้น (เปลี่ยนชื่อไม่ได้ เพราะ users.role อ้างด้วยชื่อ)
+	DisplayName string   `json:"display_name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type roleDTO struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"display_name"`
+	Description string    `json:"description"`
+	System      bool      `json:"system"`
+	Permissions []string  `json:"permissions"`
+	UserCount   int64     `json:"user_count"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type assignRoleReq struct {
+	Role string `json:"role"`
+}
+
+type userRoleDTO struct {
+	ID        uint   `json:"id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	TeacherID *uint  `json:"teacher_id"`
+	Enabled   bool   `json:"enabled"`
+}
+
+/* ====================== Helpers ====================== */
+
+func toRoleDTO(r models.Role) roleDTO {
+	perms := make([]string, 0)
+	if r.Name == roleAdmin {
+		for _, p := range permissionCatalogue {
+			perms = append(perms, p.Key)
+		}
+	} else {
+		for _, p := range permissionCatalogue {
+			for _, rp := range r.Permissions {
+				if rp.Permission == p.Key {
+					perms = append(perms, p.Key)
+					break
+				}
+			}
+		}
+	}
+	var cnt int64
+	_ = database.DB.Model(&models.User{}).Where("role = ?", r.Name).Count(&cnt).Error
+	return roleDTO{
+		ID:          r.ID,
+		Name:        r.Name,
+		DisplayName: r.DisplayName,
+		Description: r.Description,
+		System:      r.System,
+		Permissions: perms,
+		UserCount:   cnt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+// ตรวจรายการสิทธิ์: ต้องอยู่ใน catalogue ทุกข้อ
+func validatePermissions(perms []string) (bad []string) {
+	for _, p := range perms {
+		if !isKnownPermission(p) {
+			bad = append(bad, p)
+		}
+	}
+	return bad
+}
+
+func roleExists(name string) bool {
+	var n int64
+	if err := database.DB.Model(&models.Role{}).Where("name = ?", name).Count(&n).Error; err != nil {
+		return false
+	}
+	return n > 0
+}
+
+func (h *RoleHandler) findRole(c echo.Context) (*models.Role, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return nil, c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	var r models.Role
+	if err := database.DB.Preload("Permissions").First(&r, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+		}
+		return nil, c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	return &r, nil
+}
+
+/* ====================== Handlers ====================== */
+
+// GET /permissions — catalogue สิทธิ์ทั้งหมด
+func (h *RoleHandler) Permissions(c echo.Context) error {
+	return c.JSON(http.StatusOK, permissionCatalogue)
+}
+
+// GET /roles
+func (h *RoleHandler) List(c echo.Context) error {
+	var rows []models.Role
+	if err := database.DB.Preload("Permissions").Order("system DESC, name ASC").Find(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	out := make([]roleDTO, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, toRoleDTO(r))
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+// POST /roles
+// body: { name, display_name, description, permissions[] }
+func (h *RoleHandler) Create(c echo.Context) error {
+	var req roleReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	req.Name = strings.ToLower(strings.TrimSpace(req.Name))
+	req.DisplayName = strings.TrimSpace(req.DisplayName)
+	req.Description = strings.TrimSpace(req.Description)
+
+	fields := map[string]string{}
+	if !reRoleName.MatchString(req.Name) {
+		fields["name"] = "invalid"
+	} else if req.Name == roleParent {
+		fields["name"] = "reserved"
+	}
+	if bad := validatePermissions(req.Permissions); len(bad) > 0 {
+		fields["permissions"] = "unknown: " + strings.Join(bad, ",")
+	}
+	if len(fields) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "VALIDATION_ERROR", "fields": fields})
+	}
+	if roleExists(req.Name) {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "ROLE_EXISTS"})
+	}
+
+	r := models.Role{Name: req.Name, DisplayName: req.DisplayName, Description: req.Description}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&r).Error; err != nil {
+			return err
+		}
+		return setRolePermissions(tx, r.ID, req.Permissions)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	_ = database.DB.Preload("Permissions").First(&r, r.ID).Error
+	return c.JSON(http.StatusCreated, toRoleDTO(r))
+}
+
+// PUT /roles/:id
+// body: { display_name, description, permissions[] } (ชื่อ role เปลี่ยนไม่ได้, admin แก้สิทธิ์ไม่ได้)
+func (h *RoleHandler) Update(c echo.Context) error {
+	r, err := h.findRole(c)
+	if r == nil {
+		return err
+	}
+	var req roleReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	if r.Name == roleAdmin && req.Permissions != nil {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "ROLE_IMMUTABLE"})
+	}
+	if bad := validatePermissions(req.Permissions); len(bad) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+			"error":  "VALIDATION_ERROR",
+			"fields": map[string]string{"permissions": "unknown: " + strings.Join(bad, ",")},
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(r).Updates(map[string]any{
+			"display_name": strings.TrimSpace(req.DisplayName),
+			"description":  strings.TrimSpace(req.Description),
+		}).Error; err != nil {
+			return err
+		}
+		if req.Permissions == nil {
+			return nil
+		}
+		return setRolePermissions(tx, r.ID, req.Permissions)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	_ = database.DB.Preload("Permissions").First(r, r.ID).Error
+	return c.JSON(http.StatusOK, toRoleDTO(*r))
+}
+
+// DELETE /roles/:id — ลบได้เฉพาะ role ที่ไม่ใช่ของระบบและไม่มีผู้ใช้อยู่
+func (h *RoleHandler) Delete(c echo.Context) error {
+	r, err := h.findRole(c)
+	if r == nil {
+		return err
+	}
+	if r.System {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "ROLE_IMMUTABLE"})
+	}
+	var n int64
+	if err := database.DB.Model(&models.User{}).Where("role = ?", r.Name).Count(&n).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	if n > 0 {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "ROLE_IN_USE", "user_count": n})
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", r.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role = ?", r.Name).Delete(&models.TwoFactorPolicy{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(r).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GET /users?role=&q=
+func (h *RoleHandler) ListUsers(c echo.Context) error {
+	tx := database.DB.Model(&models.User{})
+	if role := strings.TrimSpace(c.QueryParam("role")); role != "" {
+		tx = tx.Where("role = ?", role)
+	}
+	if q := strings.TrimSpace(c.QueryParam("q")); q != "" {
+		tx = tx.Where("username ILIKE ?", "%"+q+"%")
+	}
+	var users []models.User
+	if err := tx.Order("username ASC").Find(&users).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	out := make([]userRoleDTO, 0, len(users))
+	for _, u := range users {
+		out = append(out, userRoleDTO{ID: u.ID, Username: u.Username, Role: u.Role, TeacherID: u.TeacherID, Enabled: u.Enabled})
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+// PUT /users/:id/role
+// body: { role } — มีผลทันที (RequireAuth อ่าน role จาก DB ทุก request)
+func (h *RoleHandler) AssignRole(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	var req assignRoleReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	role := strings.ToLower(strings.TrimSpace(req.Role))
+	if !roleExists(role) {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "ROLE_NOT_FOUND"})
+	}
+	if p := currentPrincipal(c); p != nil && p.UserID == uint(id) {
+		// กันถอดสิทธิ์ตัวเองโดยไม่ตั้งใจ
+		return c.JSON(http.StatusForbidden, map[string]any{"error": "CANNOT_CHANGE_OWN_ROLE"})
+	}
+
+	var u models.User
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&u, id).Error; err != nil {
+			return err
+		}
+		if u.Role == roleAdmin && role != roleAdmin {
+			var admins int64
+			if err := tx.Model(&models.User{}).
+				Where("role = ? AND enabled = ?", roleAdmin, true).
+				Count(&admins).Error; err != nil {
+				return err
+			}
+			if admins <= 1 {
+				return errLastAdmin
+			}
+		}
+		return tx.Model(&u).Update("role", role).Error
+	})
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		return c.JSON(http.StatusNotFound, map[string]any{"error": "NOT_FOUND"})
+	case err == errLastAdmin:
+		return c.JSON(http.StatusConflict, map[string]any{"error": "LAST_ADMIN"})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusOK, userRoleDTO{ID: u.ID, Username: u.Username, Role: u.Role, TeacherID: u.TeacherID, Enabled: u.Enabled})
+}
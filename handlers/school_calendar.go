@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+// สรุปวันตามปฏิทินการศึกษา (calendar_items)
+type schoolDayInfo struct {
+	Date      string
+	SchoolDay bool
+	Reason    string               // ไม่ใช่วันเรียนเพราะ: weekend | holiday | no_term
+	Holiday   string               // ชื่อวันหยุด (Reason = holiday)
+	Term      *models.CalendarItem // ภาคเรียนที่ครอบคลุมวันนั้น (nil = นอกภาคเรียน)
+}
+
+// ภาคเรียน + วันหยุดที่ทับช่วงวันที่หนึ่ง (โหลดครั้งเดียวแล้วถามทีละวัน)
+type schoolCalendar struct {
+	terms    []models.CalendarItem
+	holidays []models.CalendarItem
+}
+
+// from/to = YYYY-MM-DD (รวมปลายทั้งสองข้าง)
+func loadSchoolCalendar(from, to string) *schoolCalendar {
+	sc := &schoolCalendar{}
+	_ = database.DB.
+		Where("type = ? AND open_date <= ? AND close_date >= ?", "normal", to, from).
+		Order("open_date DESC").
+		Find(&sc.terms).Error
+	// วันหยุดหลายวัน: start_date..end_date (end_date ว่าง = วันเดียว)
+	_ = database.DB.
+		Where("type = ? AND start_date <= ? AND COALESCE(NULLIF(end_date, ''), start_date) >= ?", "holiday", to, from).
+		Order("start_date ASC").
+		Find(&sc.holidays).Error
+	return sc
+}
+
+func (sc *schoolCalendar) day(date string) schoolDayInfo {
+	info := schoolDayInfo{Date: date}
+	for i := range sc.terms {
+		if sc.terms[i].OpenDate <= date && sc.terms[i].CloseDate >= date {
+			info.Term = &sc.terms[i]
+			break
+		}
+	}
+	if d, err := time.Parse("2006-01-02", date); err == nil {
+		if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			info.Reason = "weekend"
+			return info
+		}
+	}
+	for _, hol := range sc.holidays {
+		end := hol.EndDate
+		if end == "" {
+			end = hol.StartDate
+		}
+		if hol.StartDate <= date && end >= date {
+			info.Reason = "holiday"
+			info.Holiday = strings.TrimSpace(hol.Name)
+			return info
+		}
+	}
+	if info.Term == nil {
+		info.Reason = "no_term"
+		return info
+	}
+	info.SchoolDay = true
+	return info
+}
+
+// วันเรียนทั้งหมดในช่วง (เรียงตามวันที่)
+func (sc *schoolCalendar) schoolDays(from, to string) []string {
+	start, err1 := time.Parse("2006-01-02", from)
+	end, err2 := time.Parse("2006-01-02", to)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	var out []string
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		if sc.day(date).SchoolDay {
+			out = append(out, date)
+		}
+	}
+	return out
+}
+
+// date = YYYY-MM-DD
+func schoolDayOf(date string) schoolDayInfo {
+	return loadSchoolCalendar(date, date).day(date)
+}
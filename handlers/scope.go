@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+/*
+	ขอบเขตข้อมูล (data scope) ของผู้ใช้ที่ login อยู่
+
+	  - สิทธิ์ classes.all (admin ฯลฯ)  → เห็นทุกห้อง
+	  - นอกนั้น                         → เห็นเฉพาะชั้น/ห้องที่ตัวเองเป็นครูประจำชั้น (หลักหรือรอง)
+	                                      ของปีการศึกษาปัจจุบัน และสถานะ "ปฏิบัติงาน"
+
+	รายการ: กรองทิ้งเงียบ ๆ, รายการเดี่ยวที่อยู่นอกขอบเขต: ตอบ 404 (ไม่บอกว่ามีอยู่จริง)
+*/
+
+const homeroomActive = "ปฏิบัติงาน"
+
+type classScope struct {
+	All     bool
+	Classes [][2]string // {grade, room}
+}
+
+// ปีการศึกษาปัจจุบัน (พ.ศ.):
+// 1) ภาคเรียน (calendar type normal) ที่ครอบคลุมวันนี้
+// 2) ไม่มี → ปีล่าสุดในตาราง homerooms
+// 3) ไม่มีอีก → คำนวณ (ปีการศึกษาไทยเริ่มพฤษภาคม)
+func currentAcademicYear() string {
+	today := time.Now().Format("2006-01-02")
+	var year string
+	_ = database.DB.Model(&models.CalendarItem{}).
+		Select("academic_year").
+		Where("type = ? AND open_date <= ? AND close_date >= ? AND academic_year <> ''", "normal", today, today).
+		Order("open_date DESC").Limit(1).
+		Scan(&year).Error
+	if year = strings.TrimSpace(year); year != "" {
+		return year
+	}
+	_ = database.DB.Model(&models.Homeroom{}).Select("MAX(academic_year)").Scan(&year).Error
+	if year = strings.TrimSpace(year); year != "" {
+		return year
+	}
+	now := time.Now()
+	y := now.Year() + 543
+	if now.Month() < time.May {
+		y--
+	}
+	return strconv.Itoa(y)
+}
+
+// ห้อง (ชั้น/ห้อง) ที่ครูคนนี้เป็นครูประจำชั้นอยู่ในปีการศึกษาปัจจุบัน
+func homeroomClassesOf(teacherID uint) [][2]string {
+	if teacherID == 0 {
+		return nil
+	}
+	var hrs []models.Homeroom
+	if err := database.DB.
+		Where("teacher_id = ? AND status = ? AND academic_year = ?", teacherID, homeroomActive, currentAcademicYear()).
+		Find(&hrs).Error; err != nil {
+		return nil
+	}
+	seen := map[[2]string]bool{}
+	out := make([][2]string, 0, len(hrs))
+	for _, hr := range hrs {
+		k := [2]string{strings.TrimSpace(hr.Grade), strings.TrimSpace(hr.Room)}
+		if !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+func scopeOf(p *Principal) classScope {
+	if p.Can(PermAllClasses) {
+		return classScope{All: true}
+	}
+	if p == nil {
+		return classScope{}
+	}
+	return classScope{Classes: homeroomClassesOf(p.TeacherID)}
+}
+
+// กรองตามคอลัมน์ชั้น/ห้อง เช่น where(tx, "s.grade", "s.room")
+func (s classScope) where(tx *gorm.DB, gradeCol, roomCol string) *gorm.DB {
+	if s.All {
+		return tx
+	}
+	if len(s.Classes) == 0 {
+		return tx.Where("1 = 0")
+	}
+	conds := make([]string, 0, len(s.Classes))
+	args := make([]any, 0, len(s.Classes)*2)
+	for _, cl := range s.Classes {
+		conds = append(conds, "("+gradeCol+" = ? AND "+roomCol+" = ?)")
+		args = append(args, cl[0], cl[1])
+	}
+	return tx.Where("("+strings.Join(conds, " OR ")+")", args...)
+}
+
+// subquery: id ของนักเรียนในขอบเขต (ใช้กับตารางที่มี student_id)
+func (s classScope) studentIDs() *gorm.DB {
+	return s.where(database.DB.Model(&models.Student{}).Select("id"), "grade", "room")
+}
+
+// กรองตาราง (ที่มีคอลัมน์ student_id) ให้เหลือเฉพาะนักเรียนในขอบเขต
+func (s classScope) students(tx *gorm.DB, studentIDCol string) *gorm.DB {
+	if s.All {
+		return tx
+	}
+	return tx.Where(studentIDCol+" IN (?)", s.studentIDs())
+}
+
+func (s classScope) allows(grade, room string) bool {
+	if s.All {
+		return true
+	}
+	grade, room = strings.TrimSpace(grade), strings.TrimSpace(room)
+	for _, cl := range s.Classes {
+		if cl[0] == grade && cl[1] == room {
+			return true
+		}
+	}
+	return false
+}
+
+// นักเรียนคนนี้อยู่ในขอบเขตไหม (ไม่พบนักเรียน = ไม่อยู่)
+func (s classScope) allowsStudent(studentID uint) bool {
+	var stu models.Student
+	if err := database.DB.Select("id", "grade", "room").First(&stu, studentID).Error; err != nil {
+		return false
+	}
+	return s.allows(stu.Grade, stu.Room)
+}
@@ -162,7 +162,7 @@ func (h *StudentHandler) List(c echo.Context) error {
 	}
 
 	var items []models.Student
-	tx := database.DB.Model(&models.Student{})
+	tx := scopeOf(currentPrincipal(c)).where(database.DB.Model(&models.Student{}), "grade", "room")
 
 	if q != "" {
 		like := "%" + q + "%"
@@ -187,7 +187,8 @@ func (h *StudentHandler) List(c echo.Context) error {
 func (h *StudentHandler) Get(c echo.Context) error {
 	id := c.Param("id")
 	var s models.Student
-	if err := database.DB.First(&s, "id = ?", id).Error; err != nil {
+	tx := scopeOf(currentPrincipal(c)).where(database.DB.Model(&models.Student{}), "grade", "room")
+	if err := tx.First(&s, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "NOT_FOUND"})
 		}
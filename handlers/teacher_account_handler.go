@@ -32,24 +32,20 @@ type createTeacherAccountReq struct {
 	Password  string `json:"password"`
 }
 
-type patchTeacherAccountReq struct {
-	// โปรเจกต์ตอนนี้ยังไม่มีคอลัมน์รองรับจริง ๆ
-	// เก็บไว้ให้ FE เรียกได้ โดยจะ NO-OP หากไม่มีฟิลด์ใน DB
-	Enabled             *bool `json:"enabled,omitempty"`
-	ForcePasswordChange *bool `json:"force_password_change,omitempty"`
-}
-
 type resetPasswordReq struct {
 	Length int `json:"length"`
 }
 
 type teacherAccountDTO struct {
-	ID                  uint      `json:"id"`
-	TeacherID           uint      `json:"teacher_id"`
-	Username            string    `json:"username"`
-	Enabled             bool      `json:"enabled"`               // ไม่มีใน DB ตอนนี้ -> ใส่ค่า default
-	ForcePasswordChange bool      `json:"force_password_change"` // ไม่มีใน DB ตอนนี้ -> ใส่ค่า default
-	UpdatedAt           time.Time `json:"updated_at"`
+	ID                  uint       `json:"id"`
+	TeacherID           uint       `json:"teacher_id"`
+	Username            string     `json:"username"`
+	Enabled             bool       `json:"enabled"`
+	ForcePasswordChange bool       `json:"force_password_change"`
+	TwoFactorEnabled    bool       `json:"two_factor_enabled"`
+	LastLogin           *time.Time `json:"last_login"`
+	LastPasswordChange  *time.Time `json:"last_password_change"`
+	UpdatedAt           time.Time  `json:"updated_at"`
 }
 
 type updateFlagsReq struct {
@@ -71,8 +67,11 @@ func toDTO(u models.User) teacherAccountDTO {
 		ID:                  u.ID,
 		TeacherID:           tid,
 		Username:            u.Username,
-		Enabled:             true,  // ค่า default ชั่วคราว (ยังไม่มีคอลัมน์)
-		ForcePasswordChange: false, // ค่า default ชั่วคราว (ยังไม่มีคอลัมน์)
+		Enabled:             u.Enabled,
+		ForcePasswordChange: u.ForcePasswordChange,
+		TwoFactorEnabled:    u.TOTPEnabled,
+		LastLogin:           u.LastLogin,
+		LastPasswordChange:  u.LastPasswordChange,
 		UpdatedAt:           u.UpdatedAt,
 	}
 }
@@ -112,7 +111,7 @@ func randomPassword(n int) string {
 func (h *TeacherAccountHandler) List(c echo.Context) error {
 	// ดึงเฉพาะ user role=teacher ที่มี username
 	var users []models.User
-	q := database.DB.Where("role = ?", "teacher").Where("username <> ''")
+	q := database.DB.Where("role = ?", roleTeacher).Where("username <> ''")
 	if err := q.Order("updated_at desc").Find(&users).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
 	}
@@ -186,7 +185,7 @@ func (h *TeacherAccountHandler) Create(c echo.Context) error {
 	u := models.User{
 		Username:     req.Username,
 		PasswordHash: hashed,
-		Role:         "teacher",
+		Role:         roleTeacher,
 		TeacherID:    &tid,
 	}
 	if err := database.DB.Create(&u).Error; err != nil {
@@ -224,7 +223,7 @@ func (h *TeacherAccountHandler) ResetPassword(c echo.Context) error {
 		}
 		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
 	}
-	if u.Role != "teacher" {
+	if u.Role != roleTeacher {
 		return c.JSON(http.StatusForbidden, map[string]any{"error": "NOT_TEACHER_ACCOUNT"})
 	}
 
@@ -234,51 +233,25 @@ func (h *TeacherAccountHandler) ResetPassword(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "HASH_ERROR"})
 	}
 	u.PasswordHash = hash
+	// รหัสชั่วคราว → บังคับให้เปลี่ยนตอน login ครั้งถัดไป
+	u.ForcePasswordChange = true
 
 	if err := database.DB.Save(u).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
 	}
+	// รหัสเดิมอาจหลุด → ตัดทุก session ที่ค้างอยู่
+	_ = revokeAllSessions(database.DB, u.ID)
 	return c.JSON(http.StatusOK, map[string]any{"one_time_password": newPW})
 }
 
 // -----------------------------
-// Patch fields (NO-OP ถ้าโปรเจกต์ยังไม่มีคอลัมน์)
+// Patch fields (alias ของ UpdateFlags)
 // PATCH /admin/teacher-accounts/:id
 // body: { enabled?, force_password_change? }
 // -----------------------------
 
 func (h *TeacherAccountHandler) Patch(c echo.Context) error {
-	idStr := c.Param("id")
-	id64, _ := strconv.ParseUint(idStr, 10, 64)
-	if id64 == 0 {
-		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
-	}
-
-	var req patchTeacherAccountReq
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
-	}
-
-	u, err := h.findUserByID(uint(id64))
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, map[string]any{"error": "ACCOUNT_NOT_FOUND"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
-	}
-	if u.Role != "teacher" {
-		return c.JSON(http.StatusForbidden, map[string]any{"error": "NOT_TEACHER_ACCOUNT"})
-	}
-
-	// ตอนนี้โปรเจกต์ยังไม่มีคอลัมน์ enabled/force_password_change ในตาราง users
-	// ถ้าส่งมาแต่ไม่มีอะไรอัปเดตจริง ให้แจ้งกลับว่าไม่มีฟิลด์ให้ปรับ
-	if req.Enabled == nil && req.ForcePasswordChange == nil {
-		return c.JSON(http.StatusBadRequest, map[string]any{"error": "NO_FIELDS_TO_UPDATE"})
-	}
-
-	// ทำ NO-OP แล้วคืนค่าปัจจุบันกลับไป (ค่า default)
-	// หากอนาคตเพิ่มคอลัมน์ใน models.User เมื่อไร มาปรับตรงนี้ให้เซฟจริงได้ทันที
-	return c.JSON(http.StatusOK, toDTO(*u))
+	return h.UpdateFlags(c)
 }
 
 func (h *TeacherAccountHandler) UpdateFlags(c echo.Context) error {
@@ -315,6 +288,10 @@ func (h *TeacherAccountHandler) UpdateFlags(c echo.Context) error {
 	if err := database.DB.Model(&u).Updates(updates).Error; err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": err.Error()})
 	}
+	// ปิดบัญชี → ตัดทุก session ทันที (RequireAuth เช็ค enabled อยู่แล้ว แต่ไม่ให้ refresh ต่อได้ด้วย)
+	if req.Enabled != nil && !*req.Enabled {
+		_ = revokeAllSessions(database.DB, u.ID)
+	}
 
 	// reload to return latest
 	if err := database.DB.First(&u, id).Error; err != nil {
@@ -327,6 +304,68 @@ func (h *TeacherAccountHandler) UpdateFlags(c echo.Context) error {
 		"username":              u.Username,
 		"enabled":               u.Enabled,
 		"force_password_change": u.ForcePasswordChange,
+		"two_factor_enabled":    u.TOTPEnabled,
+		"last_login":            u.LastLogin,
+		"last_password_change":  u.LastPasswordChange,
 		"updated_at":            u.UpdatedAt,
 	})
 }
+
+// -----------------------------
+// Revoke all sessions (เช่น ครูทำมือถือหาย)
+// POST /admin/teacher-accounts/:id/logout
+// -----------------------------
+
+func (h *TeacherAccountHandler) RevokeSessions(c echo.Context) error {
+	idStr := c.Param("id")
+	id64, _ := strconv.ParseUint(idStr, 10, 64)
+	if id64 == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	u, err := h.findUserByID(uint(id64))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, map[string]any{"error": "ACCOUNT_NOT_FOUND"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	if err := revokeAllSessions(database.DB, u.ID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"ok": true})
+}
+
+// -----------------------------
+// Reset 2FA (เช่น ครูทำมือถือหาย/ลบแอพ)
+// POST /admin/teacher-accounts/:id/2fa/reset
+// ปิด 2FA + ตัดทุก session → login ใหม่ด้วยรหัสผ่าน (ถ้า role ถูกบังคับ 2FA จะต้องตั้งใหม่ทันที)
+// -----------------------------
+
+func (h *TeacherAccountHandler) ResetTwoFactor(c echo.Context) error {
+	idStr := c.Param("id")
+	id64, _ := strconv.ParseUint(idStr, 10, 64)
+	if id64 == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_ID"})
+	}
+	u, err := h.findUserByID(uint(id64))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, map[string]any{"error": "ACCOUNT_NOT_FOUND"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_ERROR"})
+	}
+	if p := currentPrincipal(c); p != nil && p.UserID == u.ID {
+		// ของตัวเองให้ปิดผ่าน /auth/2fa/disable (ต้องยืนยันรหัสผ่าน + รหัส 6 หลัก)
+		return c.JSON(http.StatusForbidden, map[string]any{"error": "CANNOT_RESET_SELF"})
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := clearTwoFactor(tx, u.ID); err != nil {
+			return err
+		}
+		return revokeAllSessions(tx, u.ID)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"ok": true})
+}
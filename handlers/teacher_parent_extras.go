@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+
+	"github.com/patiponrmutl/BESystem/database"
 )
 
 // สตับสำหรับ /teacher/attendance/mark
@@ -14,10 +16,39 @@ func MarkAttendance(c echo.Context) error {
 	})
 }
 
-// สตับสำหรับ /parent/children
+// GET /parent/children
+// คืนเฉพาะลูกที่ผูกกับผู้ปกครองคนนี้และอนุมัติแล้ว พร้อมชั้น/ห้องปัจจุบัน
 func ParentChildren(c echo.Context) error {
-	return c.JSON(http.StatusNotImplemented, map[string]any{
-		"error": "NOT_IMPLEMENTED",
-		"hint":  "return children list for the parent (by JWT subject)",
-	})
+	p := currentPrincipal(c)
+	if !p.IsParent() {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
+	}
+
+	type child struct {
+		ID             uint   `json:"id"`
+		StudentCode    string `json:"student_code"`
+		Prefix         string `json:"prefix"`
+		FirstName      string `json:"first_name"`
+		LastName       string `json:"last_name"`
+		EducationStage string `json:"education_stage"`
+		Grade          string `json:"grade"`
+		Room           string `json:"room"`
+		Status         string `json:"status"`
+		Relation       string `json:"relation"`
+	}
+
+	var rows []child
+	if err := database.DB.Table("parent_students AS ps").
+		Joins("JOIN students s ON s.id = ps.student_id").
+		Select(`s.id, s.student_id AS student_code, s.prefix, s.first_name, s.last_name,
+			s.education AS education_stage, s.grade, s.room, s.status, ps.relation`).
+		Where("ps.parent_id = ? AND ps.status = ?", p.ParentID, linkApproved).
+		Order("s.grade, s.room, s.student_id").
+		Scan(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	if rows == nil {
+		rows = []child{}
+	}
+	return c.JSON(http.StatusOK, rows)
 }
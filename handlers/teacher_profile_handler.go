@@ -16,10 +16,7 @@ import (
 )
 
 /*
-Context values ที่ JWT middleware ของคุณตั้งไว้:
-- user_id (uint)
-- role    (string)
-- name    (string)
+ผู้ใช้ที่ login อยู่อ่านจาก currentPrincipal(c) (RequireAuth เป็นคนใส่)
 */
 
 // --------------------------------------------------------------------
@@ -76,13 +73,21 @@ func (h *TeacherProfileHandler) ChangePassword(c echo.Context) error {
 		})
 	}
 
-	uid, ok := getUserIDFromContext(c)
-	if !ok || uid == 0 {
+	if len(req.NewPassword) < 8 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+			"error":  "VALIDATION_ERROR",
+			"fields": map[string]string{"new_password": "min_length_8"},
+		})
+	}
+
+	// ใช้ได้ทั้ง token ปกติและ token จำกัดสิทธิ์ (บัญชีที่ถูกบังคับเปลี่ยนรหัส)
+	p := currentPrincipal(c)
+	if p == nil || p.UserID == 0 {
 		return c.JSON(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
 	}
 
 	var u models.User
-	if err := database.DB.First(&u, uid).Error; err != nil {
+	if err := database.DB.First(&u, p.UserID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.JSON(http.StatusNotFound, map[string]any{"error": "USER_NOT_FOUND"})
 		}
@@ -93,6 +98,12 @@ func (h *TeacherProfileHandler) ChangePassword(c echo.Context) error {
 	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.OldPassword)); err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]any{"error": "INVALID_OLD_PASSWORD"})
 	}
+	if req.OldPassword == req.NewPassword {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+			"error":  "VALIDATION_ERROR",
+			"fields": map[string]string{"new_password": "must_differ"},
+		})
+	}
 
 	// สร้าง hash ใหม่
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
@@ -100,33 +111,30 @@ func (h *TeacherProfileHandler) ChangePassword(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "HASH_ERROR"})
 	}
 
-	// อัปเดต
-	if err := database.DB.Model(&u).Update("password_hash", string(hash)).Error; err != nil {
+	// อัปเดต + ปลดธงบังคับเปลี่ยนรหัส + บันทึกเวลาเปลี่ยนรหัสล่าสุด
+	now := time.Now()
+	if err := database.DB.Model(&u).Updates(map[string]any{
+		"password_hash":         string(hash),
+		"force_password_change": false,
+		"last_password_change":  &now,
+	}).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "SAVE_ERROR"})
 	}
 
-	return c.JSON(http.StatusOK, map[string]any{"ok": true})
+	// เครื่องอื่นต้อง login ใหม่ (session ปัจจุบันยังใช้ต่อได้ ถ้ามี)
+	_ = revokeOtherSessions(database.DB, u.ID, p.SessionID)
+
+	// token จำกัดสิทธิ์ใช้ต่อไม่ได้แล้ว → FE ต้อง login ใหม่
+	return c.JSON(http.StatusOK, map[string]any{
+		"ok":               true,
+		"relogin_required": p.Scope == scopePasswordChange,
+	})
 }
 
 // --------------------------------------------------------------------
 // Utilities
 // --------------------------------------------------------------------
 
-func currentUser(c echo.Context) (uid uint, role string) {
-	roleAny := c.Get("role")
-	role, _ = roleAny.(string)
-	idAny := c.Get("user_id")
-	switch v := idAny.(type) {
-	case uint:
-		uid = v
-	case int:
-		uid = uint(v)
-	default:
-		uid = 0
-	}
-	return
-}
-
 // map user → teacher
 // 1) โดยปกติ: map จาก users.username = teachers.teacher_code
 // 2) fallback: หา teacher ด้วย phone/email ถ้าต้องการ
@@ -181,10 +189,11 @@ func findLatestHomeroom(teacherID uint) *models.Homeroom {
 
 // GET /teacher/me
 func TeacherMe(c echo.Context) error {
-	uid, role := currentUser(c)
-	if uid == 0 || (role != "teacher" && role != "admin") {
+	p := currentPrincipal(c)
+	if p == nil || p.UserID == 0 {
 		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
 	}
+	uid := p.UserID
 
 	var u models.User
 	if err := database.DB.First(&u, uid).Error; err != nil {
@@ -192,9 +201,10 @@ func TeacherMe(c echo.Context) error {
 	}
 
 	var t *models.Teacher
-	if role == "teacher" || role == "admin" {
-		if tt, err := findTeacherForUser(&u); err == nil {
-			t = tt
+	if p.TeacherID != 0 {
+		var tt models.Teacher
+		if err := database.DB.First(&tt, p.TeacherID).Error; err == nil {
+			t = &tt
 		}
 	}
 
@@ -235,10 +245,11 @@ func TeacherMe(c echo.Context) error {
 
 // GET /teacher/profile
 func TeacherGetProfile(c echo.Context) error {
-	uid, role := currentUser(c)
-	if uid == 0 || (role != "teacher" && role != "admin") {
+	p := currentPrincipal(c)
+	if p == nil || p.UserID == 0 {
 		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
 	}
+	uid := p.UserID
 	var u models.User
 	if err := database.DB.First(&u, uid).Error; err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "USER_NOT_FOUND"})
@@ -255,10 +266,11 @@ func TeacherGetProfile(c echo.Context) error {
 
 // PUT /teacher/profile
 func TeacherUpdateProfile(c echo.Context) error {
-	uid, role := currentUser(c)
-	if uid == 0 || (role != "teacher" && role != "admin") {
+	p := currentPrincipal(c)
+	if p == nil || p.UserID == 0 {
 		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "UNAUTHORIZED"})
 	}
+	uid := p.UserID
 
 	var req profileUpdateRequest
 	if err := c.Bind(&req); err != nil {
@@ -291,13 +303,8 @@ func TeacherUpdateProfile(c echo.Context) error {
 	}
 
 	// sync phone ไปตารางครู (ถ้ามี)
-	if req.Phone != "" {
-		var u models.User
-		if err := database.DB.First(&u, uid).Error; err == nil {
-			if t, err := findTeacherForUser(&u); err == nil && t != nil {
-				_ = database.DB.Model(&models.Teacher{}).Where("id = ?", t.ID).Update("phone", req.Phone).Error
-			}
-		}
+	if req.Phone != "" && p.TeacherID != 0 {
+		_ = database.DB.Model(&models.Teacher{}).Where("id = ?", p.TeacherID).Update("phone", req.Phone).Error
 	}
 
 	return c.JSON(http.StatusOK, map[string]any{"ok": true})
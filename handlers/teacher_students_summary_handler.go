@@ -40,6 +40,7 @@ func (h *TeacherStudentsSummaryHandler) List(c echo.Context) error {
 
 	tx := database.DB.Table("students").
 		Select("id, student_id AS code, prefix, first_name, last_name, grade, room")
+	tx = scopeOf(currentPrincipal(c)).where(tx, "grade", "room")
 
 	if grade != "" {
 		tx = tx.Where("grade = ?", grade)
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"os"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/patiponrmutl/BESystem/database"
+)
+
+// ฐานข้อมูลสำหรับ test ที่ต้องใช้ DB จริง: ตั้ง TEST_DATABASE_DSN เป็น Postgres ที่ทิ้งได้ (ไม่ตั้ง = ข้าม)
+// ทุก test ทำงานใน transaction เดียวแล้ว rollback ตอนจบ → ไม่ทิ้งข้อมูลไว้
+func useTestDB(t *testing.T, tables ...any) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect test db: %v", err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("migrate test db: %v", err)
+	}
+	tx := db.Begin()
+	prev := database.DB
+	database.DB = tx
+	t.Cleanup(func() {
+		database.DB = prev
+		tx.Rollback()
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
@@ -0,0 +1,501 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+// scope ของ token ระหว่างขั้นตอน 2FA
+const (
+	scopeMFA       = "mfa"        // ผ่านรหัสผ่านแล้ว รอรหัส 6 หลัก (ใช้ได้กับ /auth/login/2fa เท่านั้น)
+	scopeMFAEnroll = "mfa_enroll" // role นี้ถูกบังคับ 2FA แต่ยังไม่ได้ตั้ง → ใช้ได้เฉพาะเส้นตั้งค่า 2FA
+)
+
+const (
+	totpPeriod        = 30 // วินาทีต่อรหัส
+	totpDigits        = 6
+	totpSkew          = 1 // ยอมรับรหัสก่อน/หลังได้ 1 ช่วง (นาฬิกามือถือคลาด)
+	recoveryCodeCount = 10
+	mfaChallengeTTL   = 5 * time.Minute
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+/* ====================== TOTP (RFC 6238) ====================== */
+
+func newTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(b), nil
+}
+
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, bin%1000000)
+}
+
+// คืน time step ที่รหัสนี้ตรง (ใช้กันรหัสเดิมซ้ำ)
+func verifyTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits || onlyDigits(code) != code {
+		return 0, false
+	}
+	key, err := b32.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(key) == 0 {
+		return 0, false
+	}
+	cur := now.Unix() / totpPeriod
+	for d := int64(-totpSkew); d <= totpSkew; d++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, cur+d)), []byte(code)) == 1 {
+			return cur + d, true
+		}
+	}
+	return 0, false
+}
+
+// otpauth:// URI สำหรับให้แอพ (Google Authenticator ฯลฯ) สแกนเป็น QR
+func totpURI(account, secret string) string {
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "BESystem"
+	}
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", strconv.Itoa(totpDigits))
+	q.Set("period", strconv.Itoa(totpPeriod))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+/* ====================== Recovery codes ====================== */
+
+// สร้าง recovery code ชุดใหม่: คืนตัวจริง (แสดงครั้งเดียว) + hash ที่เก็บลง DB
+func newRecoveryCodes() ([]string, string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", err
+		}
+		s := strings.ToLower(b32.EncodeToString(b)) // 8 ตัวอักษร
+		code := s[:4] + "-" + s[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, strings.Join(hashes, ","), nil
+}
+
+func normalizeRecoveryCode(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.NewReplacer("-", "", " ", "").Replace(s)
+}
+
+func recoveryCodesLeft(u *models.User) int {
+	if u.TOTPRecoveryCodes == "" {
+		return 0
+	}
+	return len(strings.Split(u.TOTPRecoveryCodes, ","))
+}
+
+/* ====================== Verify (ใช้แล้วทิ้ง) ====================== */
+
+// ตรวจรหัส 6 หลัก + บันทึก step ที่ใช้ (update แบบมีเงื่อนไข กันสองคำขอใช้รหัสเดียวกัน)
+func consumeTOTP(u *models.User, code string) bool {
+	step, ok := verifyTOTP(u.TOTPSecret, code, time.Now())
+	if !ok || step <= u.TOTPLastStep {
+		return false
+	}
+	res := database.DB.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", u.ID, step).
+		Update("totp_last_step", step)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false
+	}
+	u.TOTPLastStep = step
+	return true
+}
+
+// ใช้ recovery code ได้ครั้งเดียว (ลบ hash ออกจากรายการ)
+func consumeRecoveryCode(u *models.User, code string) bool {
+	h := hashToken(normalizeRecoveryCode(code))
+	if u.TOTPRecoveryCodes == "" {
+		return false
+	}
+	list := strings.Split(u.TOTPRecoveryCodes, ",")
+	rest := make([]string, 0, len(list))
+	found := false
+	for _, x := range list {
+		if !found && subtle.ConstantTimeCompare([]byte(x), []byte(h)) == 1 {
+			found = true
+			continue
+		}
+		rest = append(rest, x)
+	}
+	if !found {
+		return false
+	}
+	next := strings.Join(rest, ",")
+	res := database.DB.Model(&models.User{}).
+		Where("id = ? AND totp_recovery_codes = ?", u.ID, u.TOTPRecoveryCodes).
+		Update("totp_recovery_codes", next)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false
+	}
+	u.TOTPRecoveryCodes = next
+	return true
+}
+
+// ตรวจ code (6 หลัก) หรือ recovery_code อย่างใดอย่างหนึ่ง
+func consumeSecondFactor(u *models.User, code, recovery string) (bool, string) {
+	if strings.TrimSpace(recovery) != "" {
+		return consumeRecoveryCode(u, recovery), "recovery_code"
+	}
+	return consumeTOTP(u, code), "totp"
+}
+
+/* ====================== Policy ====================== */
+
+// role นี้ถูกบังคับให้ใช้ 2FA ไหม
+func twoFactorRequired(role string) bool {
+	var pol models.TwoFactorPolicy
+	if err := database.DB.Where("role = ?", strings.ToLower(role)).First(&pol).Error; err != nil {
+		return false
+	}
+	return pol.Required
+}
+
+/* ====================== DTOs ====================== */
+
+type loginTwoFactorReq struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`          // รหัส 6 หลักจากแอพ
+	RecoveryCode string `json:"recovery_code"` // ใช้แทน code กรณีไม่มีมือถือ
+}
+
+type twoFactorCodeReq struct {
+	Code string `json:"code"`
+}
+
+type twoFactorDisableReq struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type twoFactorPolicyReq struct {
+	Role     string `json:"role"`
+	Required *bool  `json:"required"`
+}
+
+/* ====================== Login step 2 ====================== */
+
+// POST /auth/login/2fa
+// body: { mfa_token, code | recovery_code }
+// mfa_token ได้จาก /auth/login เมื่อบัญชีเปิด 2FA ไว้
+func (h *AuthHandler) LoginTwoFactor(c echo.Context) error {
+	var req loginTwoFactorReq
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	if strings.TrimSpace(req.MFAToken) == "" || (strings.TrimSpace(req.Code) == "" && strings.TrimSpace(req.RecoveryCode) == "") {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]any{"error": "MISSING_FIELDS"})
+	}
+	claims, err := h.parseToken(strings.TrimSpace(req.MFAToken))
+	if err != nil || asString(claims["scope"]) != scopeMFA {
+		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_MFA_TOKEN"})
+	}
+	uid, ok := claimUint(claims["sub"])
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_MFA_TOKEN"})
+	}
+
+	var u models.User
+	if err := database.DB.First(&u, uid).Error; err != nil || !u.TOTPEnabled {
+		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_MFA_TOKEN"})
+	}
+	if !u.Enabled {
+		return echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "ACCOUNT_DISABLED"})
+	}
+	// นับรวมกับตัวนับ login ผิดของ username เดียวกัน (เดารหัส 6 หลักก็โดนล็อก)
+	if err := h.Login.check(c, u.Username); err != nil {
+		return err
+	}
+	ok, method := consumeSecondFactor(&u, req.Code, req.RecoveryCode)
+	if !ok {
+		h.Login.recordFailure(c, u.Username, "INVALID_2FA_CODE")
+		return echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "INVALID_2FA_CODE"})
+	}
+	h.Login.recordSuccess(u.Username)
+
+	resp, err := h.completeStaffLogin(c, &u)
+	if err != nil {
+		return err
+	}
+	if method == "recovery_code" {
+		resp["recovery_codes_left"] = recoveryCodesLeft(&u)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+/* ====================== Enrolment ====================== */
+
+// GET /auth/2fa
+func (h *AuthHandler) TwoFactorStatus(c echo.Context) error {
+	u, err := principalUser(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"enabled":             u.TOTPEnabled,
+		"enabled_at":          u.TOTPEnabledAt,
+		"required":            twoFactorRequired(u.Role),
+		"recovery_codes_left": recoveryCodesLeft(u),
+	})
+}
+
+// POST /auth/2fa/setup
+// สร้าง secret ใหม่ (ยังไม่เปิดใช้) → คืน secret + otpauth_uri ให้ frontend ทำ QR
+// ต้องยืนยันด้วย /auth/2fa/enable ก่อนถึงจะมีผล
+func (h *AuthHandler) TwoFactorSetup(c echo.Context) error {
+	u, err := principalUser(c)
+	if err != nil {
+		return err
+	}
+	if u.TOTPEnabled {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "TWO_FACTOR_ALREADY_ENABLED"})
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "SECRET_GEN_FAILED"})
+	}
+	if err := database.DB.Model(u).Updates(map[string]any{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"secret":      secret,
+		"otpauth_uri": totpURI(u.Username, secret),
+		"digits":      totpDigits,
+		"period":      totpPeriod,
+	})
+}
+
+// POST /auth/2fa/enable
+// body: { code } — รหัสจากแอพหลังสแกน QR
+// resp: { recovery_codes } (แสดงครั้งเดียว) + token คู่ ถ้ามาจาก token บังคับตั้ง 2FA
+func (h *AuthHandler) TwoFactorEnable(c echo.Context) error {
+	u, err := principalUser(c)
+	if err != nil {
+		return err
+	}
+	if u.TOTPEnabled {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "TWO_FACTOR_ALREADY_ENABLED"})
+	}
+	if u.TOTPSecret == "" {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "TWO_FACTOR_NOT_SETUP"})
+	}
+	var req twoFactorCodeReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	if !consumeTOTP(u, req.Code) {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "INVALID_2FA_CODE"})
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "SECRET_GEN_FAILED"})
+	}
+	now := time.Now()
+	if err := database.DB.Model(u).Updates(map[string]any{
+		"totp_enabled":        true,
+		"totp_enabled_at":     &now,
+		"totp_recovery_codes": hashes,
+	}).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	u.TOTPEnabled, u.TOTPEnabledAt = true, &now
+
+	resp := map[string]any{"ok": true, "recovery_codes": codes}
+	// ตั้งค่าจาก token บังคับตั้ง 2FA → ตั้งเสร็จแล้ว login ต่อให้เลย
+	if currentPrincipal(c).Scope == scopeMFAEnroll {
+		pair, err := h.startSession(c, subjectForUser(u))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]any{"error": "TOKEN_GEN_FAILED"})
+		}
+		for k, v := range pair {
+			resp[k] = v
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// POST /auth/2fa/disable
+// body: { password, code | recovery_code }
+// role ที่ถูกบังคับ 2FA ปิดเองไม่ได้ (ให้แอดมิน reset แทน)
+func (h *AuthHandler) TwoFactorDisable(c echo.Context) error {
+	u, err := principalUser(c)
+	if err != nil {
+		return err
+	}
+	if !u.TOTPEnabled {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "TWO_FACTOR_NOT_ENABLED"})
+	}
+	if twoFactorRequired(u.Role) {
+		return c.JSON(http.StatusForbidden, map[string]any{"error": "TWO_FACTOR_REQUIRED"})
+	}
+	var req twoFactorDisableReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.Password)) != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PASSWORD"})
+	}
+	if ok, _ := consumeSecondFactor(u, req.Code, req.RecoveryCode); !ok {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "INVALID_2FA_CODE"})
+	}
+	if err := clearTwoFactor(database.DB, u.ID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"ok": true})
+}
+
+// POST /auth/2fa/recovery-codes
+// body: { code } — ออก recovery code ชุดใหม่ (ชุดเก่าใช้ไม่ได้ทันที)
+func (h *AuthHandler) TwoFactorRecoveryCodes(c echo.Context) error {
+	u, err := principalUser(c)
+	if err != nil {
+		return err
+	}
+	if !u.TOTPEnabled {
+		return c.JSON(http.StatusConflict, map[string]any{"error": "TWO_FACTOR_NOT_ENABLED"})
+	}
+	var req twoFactorCodeReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	if !consumeTOTP(u, req.Code) {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "INVALID_2FA_CODE"})
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "SECRET_GEN_FAILED"})
+	}
+	if err := database.DB.Model(u).Update("totp_recovery_codes", hashes).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"recovery_codes": codes})
+}
+
+/* ====================== Admin ====================== */
+
+// GET /auth/2fa/policy
+func (h *AuthHandler) GetTwoFactorPolicy(c echo.Context) error {
+	var roles []string
+	if err := database.DB.Model(&models.Role{}).Pluck("name", &roles).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	out := make(map[string]bool, len(roles))
+	for _, r := range roles {
+		out[r] = false
+	}
+	var rows []models.TwoFactorPolicy
+	if err := database.DB.Find(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_QUERY_FAILED"})
+	}
+	for _, r := range rows {
+		if _, ok := out[r.Role]; ok {
+			out[r.Role] = r.Required
+		}
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+// PUT /auth/2fa/policy
+// body: { role, required: bool } — role ตามตาราง roles
+// เปิดบังคับแล้ว คนที่ยังไม่ตั้ง 2FA จะ refresh token ต่อไม่ได้ และ login ครั้งถัดไปต้องตั้งก่อน
+func (h *AuthHandler) SetTwoFactorPolicy(c echo.Context) error {
+	var req twoFactorPolicyReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "INVALID_PAYLOAD"})
+	}
+	role := strings.ToLower(strings.TrimSpace(req.Role))
+	if !roleExists(role) || req.Required == nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{
+			"error":  "VALIDATION_ERROR",
+			"fields": map[string]string{"role": "unknown_role", "required": "required"},
+		})
+	}
+	p := currentPrincipal(c)
+
+	var pol models.TwoFactorPolicy
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(models.TwoFactorPolicy{Role: role}).FirstOrCreate(&pol).Error; err != nil {
+			return err
+		}
+		pol.Required = *req.Required
+		pol.UpdatedBy = &p.UserID
+		return tx.Save(&pol).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "DB_SAVE_ERROR"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"role": pol.Role, "required": pol.Required})
+}
+
+/* ====================== Helpers ====================== */
+
+// บัญชี users ของผู้ที่ login อยู่ (ผู้ปกครองไม่มี 2FA)
+func principalUser(c echo.Context) (*models.User, error) {
+	p := currentPrincipal(c)
+	if p == nil || p.UserID == 0 {
+		return nil, echo.NewHTTPError(http.StatusForbidden, map[string]any{"error": "FORBIDDEN"})
+	}
+	var u models.User
+	if err := database.DB.First(&u, p.UserID).Error; err != nil {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, map[string]any{"error": "USER_NOT_FOUND"})
+	}
+	return &u, nil
+}
+
+// ล้าง 2FA ทั้งหมดของบัญชี (ผู้ใช้ปิดเอง หรือแอดมิน reset ให้กรณีทำมือถือหาย)
+func clearTwoFactor(db *gorm.DB, userID uint) error {
+	return db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+		"totp_secret":         "",
+		"totp_enabled":        false,
+		"totp_enabled_at":     nil,
+		"totp_last_step":      0,
+		"totp_recovery_codes": "",
+	}).Error
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patiponrmutl/BESystem/database"
+	"github.com/patiponrmutl/BESystem/models"
+)
+
+// RFC 6238 Appendix B (SHA1, key "12345678901234567890") — รหัส 8 หลักในเอกสาร ตัดเหลือ 6 หลักท้าย
+func TestTOTPCodeRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(key, tt.unix/totpPeriod); got != tt.want {
+			t.Errorf("T=%d: totpCode = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTOTPWindow(t *testing.T) {
+	key := []byte("12345678901234567890")
+	secret := b32.EncodeToString(key)
+	now := time.Unix(1111111111, 0)
+	cur := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name   string
+		code   string
+		ok     bool
+		wantAt int64
+	}{
+		{"current step", totpCode(key, cur), true, cur},
+		{"one step behind", totpCode(key, cur-1), true, cur - 1},
+		{"one step ahead", totpCode(key, cur+1), true, cur + 1},
+		{"two steps behind", totpCode(key, cur-2), false, 0},
+		{"two steps ahead", totpCode(key, cur+2), false, 0},
+		{"surrounding spaces", " " + totpCode(key, cur) + " ", true, cur},
+		{"too short", "12345", false, 0},
+		{"not digits", "12a456", false, 0},
+		{"empty", "", false, 0},
+	}
+	for _, tt := range tests {
+		step, ok := verifyTOTP(secret, tt.code, now)
+		if ok != tt.ok || step != tt.wantAt {
+			t.Errorf("%s: verifyTOTP = (%d, %v), want (%d, %v)", tt.name, step, ok, tt.wantAt, tt.ok)
+		}
+	}
+	if _, ok := verifyTOTP("not base32!", totpCode(key, cur), now); ok {
+		t.Errorf("invalid secret accepted")
+	}
+}
+
+func TestTOTPNotReusable(t *testing.T) {
+	useTestDB(t, &models.User{})
+	secret, err := newTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := models.User{Username: "totp-test", PasswordHash: "x", Role: "teacher", Enabled: true, TOTPSecret: secret, TOTPEnabled: true}
+	if err := database.DB.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	key, _ := b32.DecodeString(secret)
+	code := totpCode(key, time.Now().Unix()/totpPeriod)
+
+	if !consumeTOTP(&u, code) {
+		t.Fatalf("first use rejected")
+	}
+	if consumeTOTP(&u, code) {
+		t.Errorf("same code accepted twice")
+	}
+}
+
+func TestRecoveryCodeSingleUse(t *testing.T) {
+	useTestDB(t, &models.User{})
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), recoveryCodeCount)
+	}
+	u := models.User{Username: "recovery-test", PasswordHash: "x", Role: "teacher", Enabled: true, TOTPRecoveryCodes: hashes}
+	if err := database.DB.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"first use", codes[0], true},
+		{"second use", codes[0], false},
+		{"upper case without dash", strings.ToUpper(strings.ReplaceAll(codes[1], "-", "")), true},
+		{"reuse after normalizing", codes[1], false},
+		{"unknown code", "zzzz-zzzz", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		if got := consumeRecoveryCode(&u, tt.code); got != tt.want {
+			t.Errorf("%s: consumeRecoveryCode = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if left := recoveryCodesLeft(&u); left != recoveryCodeCount-2 {
+		t.Errorf("codes left = %d, want %d", left, recoveryCodeCount-2)
+	}
+
+	// แถวเดิมที่โหลดมาก่อนใช้ (อีกคำขอหนึ่งพร้อมกัน) ต้องใช้รหัสเดิมซ้ำไม่ได้
+	var stale models.User
+	database.DB.First(&stale, u.ID)
+	stale.TOTPRecoveryCodes = hashes
+	if consumeRecoveryCode(&stale, codes[0]) {
+		t.Errorf("used code accepted from a stale row")
+	}
+}
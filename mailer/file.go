@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer ตัวแทน SMTP สำหรับ dev: เขียนอีเมลเป็นไฟล์ .eml ใน Dir
+// (Dir ว่าง = พิมพ์ลง log อย่างเดียว)
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (f *FileMailer) Send(ctx context.Context, m Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.To = cleanHeader(m.To)
+	m.Subject = cleanHeader(m.Subject)
+	if f.Dir == "" {
+		logf("to=%s subject=%q\n%s", m.To, m.Subject, m.Body)
+		return nil
+	}
+	if err := os.MkdirAll(f.Dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000000"), sanitize(m.To))
+	path := filepath.Join(f.Dir, name)
+	if err := os.WriteFile(path, render(f.From, m), 0o600); err != nil {
+		return err
+	}
+	logf("to=%s subject=%q → %s", m.To, m.Subject, path)
+	return nil
+}
+
+func sanitize(s string) string {
+	out := []rune{}
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_', r == '@':
+			out = append(out, r)
+		default:
+			out = append(out, '_')
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,73 @@
+// Package mailer ส่งอีเมลออกจากระบบ (รีเซ็ตรหัสผ่าน ฯลฯ)
+// เลือกตัวส่งด้วย MAIL_DRIVER: smtp | file | log (ค่าเริ่มต้น log สำหรับ dev)
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"os"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // text/plain; charset=UTF-8
+}
+
+// Mailer ตัวส่งอีเมล (ต่อกับผู้ให้บริการอื่นได้โดย implement interface นี้)
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+func getenv(k, def string) string {
+	if v := strings.TrimSpace(os.Getenv(k)); v != "" {
+		return v
+	}
+	return def
+}
+
+// FromEnv สร้าง Mailer ตาม MAIL_DRIVER
+func FromEnv() Mailer {
+	from := getenv("MAIL_FROM", "no-reply@localhost")
+	switch strings.ToLower(getenv("MAIL_DRIVER", "log")) {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     getenv("SMTP_HOST", "localhost"),
+			Port:     getenv("SMTP_PORT", "587"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	case "file":
+		return &FileMailer{Dir: getenv("MAIL_FILE_DIR", "mail"), From: from}
+	default:
+		return &FileMailer{From: from}
+	}
+}
+
+// ประกอบอีเมลแบบ RFC 5322 (หัวเรื่องภาษาไทยเข้ารหัสแบบ MIME)
+func render(from string, m Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", encodeHeader(m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// กันขึ้นบรรทัดใหม่ใน header (header injection)
+func cleanHeader(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+func encodeHeader(s string) string { return mime.QEncoding.Encode("utf-8", s) }
+
+func logf(format string, args ...any) { log.Printf("[mailer] "+format, args...) }
@@ -0,0 +1,89 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+)
+
+// SMTPMailer ส่งผ่าน SMTP (ใช้ STARTTLS อัตโนมัติถ้า server รองรับ)
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string // ว่าง = ไม่ login
+	Password string
+	From     string
+}
+
+// ทำแบบเดียวกับ smtp.SendMail แต่ผูกกับ ctx: dial ตาม ctx, ตั้ง deadline ของ connection
+// และปิด connection ทันทีที่ ctx ถูกยกเลิก (server ค้างจะไม่ทำให้ goroutine ค้างตาม)
+func (s *SMTPMailer) Send(ctx context.Context, m Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.To = cleanHeader(m.To)
+	m.Subject = cleanHeader(m.Subject)
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, s.Port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	err = s.send(conn, m)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (s *SMTPMailer) send(conn net.Conn, m Message) error {
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.Hello("localhost"); err != nil {
+		return err
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("mailer: smtp server doesn't support AUTH")
+		}
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(m.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(render(s.From, m)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
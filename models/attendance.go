@@ -4,12 +4,34 @@ import "time"
 
 // บันทึกการเข้า-ออก/สถานะรายวันของนักเรียน
 type Attendance struct {
-	ID        uint   `json:"id" gorm:"primaryKey"`
-	StudentID uint   `json:"student_id" gorm:"index;not null"`
-	Date      string `json:"date" gorm:"size:10;not null"`   // YYYY-MM-DD
-	Time      string `json:"time" gorm:"size:5"`             // HH:MM (ถ้ามี)
-	Status    string `json:"status" gorm:"size:20;not null"` // เข้า/ออก/มาสาย/ขาด/ลา
-	Note      string `json:"note" gorm:"type:text"`
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	StudentID  uint   `json:"student_id" gorm:"index;not null"`
+	Date       string `json:"date" gorm:"size:10;not null"`                         // YYYY-MM-DD
+	Time       string `json:"time" gorm:"size:5"`                                   // HH:MM (ถ้ามี)
+	Status     string `json:"status" gorm:"size:20;not null"`                       // เข้า/ออก/มาสาย/ขาด/ลา (= attendance_statuses.label_th)
+	StatusCode string `json:"status_code" gorm:"size:30;index;not null;default:''"` // attendance_statuses.code ("" = ยังจับคู่ไม่ได้)
+	Note       string `json:"note" gorm:"type:text"`
+
+	// ผู้บันทึก / บันทึกย้อนหลัง
+	RecordedBy *uint     `json:"recorded_by" gorm:"index"`            // users.id ของคนที่บันทึก (null = ข้อมูลเก่าก่อนมีคอลัมน์นี้)
+	Retro      bool      `json:"retro" gorm:"not null;default:false"` // บันทึกย้อนหลัง (หลังวัน/เวลาจริง)
+	RecordedAt time.Time `json:"recorded_at"`                         // เวลาที่กดบันทึกจริง (Time = เวลาตามสถานะ)
+
+	// เทียบกับเวลาเข้า-ออกของภาคเรียน
+	MinutesLate  int  `json:"minutes_late" gorm:"not null;default:0"`    // สายกี่นาที (นับจาก TimeIn)
+	EarlyLeave   bool `json:"early_leave" gorm:"not null;default:false"` // "ออก" ก่อน TimeOut
+	MinutesEarly int  `json:"minutes_early" gorm:"not null;default:0"`
+
+	// ที่มาของข้อมูล
+	Source   string `json:"source" gorm:"size:20;not null;default:manual"` // manual | gate_qr | gate_card | system | leave
+	DeviceID *uint  `json:"device_id" gorm:"index"`                        // gate_devices.id (ถ้าสแกนหน้าประตู)
+
+	// ยกเลิก (ไม่ลบจริง — ประวัติอยู่ใน attendance_revisions)
+	VoidedAt *time.Time `json:"voided_at" gorm:"index"`
+
+	// ใบลาที่อนุมัติ: แถว "ลา" ที่สร้างจากใบลา / แถวเดิมที่ถูกแทนที่ (คืนค่าเมื่อใบลาถูกยกเลิก/เพิกถอน)
+	LeaveRequestID  *uint `json:"leave_request_id" gorm:"index"`
+	VoidedByLeaveID *uint `json:"voided_by_leave_id" gorm:"index"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
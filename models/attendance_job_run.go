@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ประวัติการรันงานเติม "ขาด" อัตโนมัติตอนเลิกเรียน (1 แถว/ครั้งที่รัน)
+type AttendanceJobRun struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Date        string     `json:"date" gorm:"size:10;not null;index"` // วันที่ที่เติม (YYYY-MM-DD)
+	Kind        string     `json:"kind" gorm:"size:20;not null"`       // schedule | manual
+	TriggeredBy *uint      `json:"triggered_by"`                       // users.id (manual)
+	Marked      int        `json:"marked"`                             // จำนวนแถว "ขาด" ที่สร้าง
+	Error       string     `json:"error" gorm:"type:text"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// ประวัติการแก้ไข/ยกเลิกแถว Attendance (1 แถว/การเปลี่ยนแปลง 1 ครั้ง เก็บค่าก่อน-หลัง)
+type AttendanceRevision struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	AttendanceID uint      `json:"attendance_id" gorm:"index;not null"`
+	Action       string    `json:"action" gorm:"size:10;not null"` // update | void | restore
+	OldStatus    string    `json:"old_status" gorm:"size:20"`
+	NewStatus    string    `json:"new_status" gorm:"size:20"`
+	OldTime      string    `json:"old_time" gorm:"size:5"`
+	NewTime      string    `json:"new_time" gorm:"size:5"`
+	OldNote      string    `json:"old_note" gorm:"type:text"`
+	NewNote      string    `json:"new_note" gorm:"type:text"`
+	Reason       string    `json:"reason" gorm:"type:text;not null"`
+	ChangedBy    *uint     `json:"changed_by"`    // users.id คนที่ขอแก้
+	ApprovedBy   *uint     `json:"approved_by"`   // users.id คนอนุมัติ (แก้ข้อมูลเก่าเกินกำหนด)
+	CorrectionID *uint     `json:"correction_id"` // attendance_corrections.id (ถ้าผ่านการอนุมัติ)
+	ChangedAt    time.Time `json:"changed_at"`
+}
+
+// คำขอแก้ข้อมูลการเข้าเรียนที่เก่าเกินกำหนด → รอ admin อนุมัติก่อนมีผล
+type AttendanceCorrection struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	AttendanceID uint       `json:"attendance_id" gorm:"index;not null"`
+	Action       string     `json:"action" gorm:"size:10;not null"` // update | void
+	NewStatus    string     `json:"new_status" gorm:"size:20"`
+	NewTime      string     `json:"new_time" gorm:"size:5"`
+	NewNote      string     `json:"new_note" gorm:"type:text"`
+	Reason       string     `json:"reason" gorm:"type:text;not null"`
+	RequestedBy  uint       `json:"requested_by" gorm:"index"`
+	Status       string     `json:"status" gorm:"size:20;not null;index"` // รออนุมัติ/อนุมัติ/ปฏิเสธ
+	DecidedBy    *uint      `json:"decided_by"`
+	DecidedAt    *time.Time `json:"decided_at"`
+	RejectReason string     `json:"reject_reason" gorm:"type:text"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
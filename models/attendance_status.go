@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// AttendanceStatus = รายการสถานะการเข้าเรียนที่ใช้ได้ (attendances.status เก็บ LabelTH, status_code เก็บ Code)
+type AttendanceStatus struct {
+	ID              uint   `json:"id" gorm:"primaryKey"`
+	Code            string `json:"code" gorm:"size:30;uniqueIndex;not null"`     // รหัสคงที่ เช่น in, late, absent (แก้ไม่ได้)
+	LabelTH         string `json:"label_th" gorm:"size:20;uniqueIndex;not null"` // ค่าที่เก็บใน attendances.status
+	LabelEN         string `json:"label_en" gorm:"size:50"`
+	Category        string `json:"category" gorm:"size:20;not null"` // present | absent | leave | pending (ยังไม่มีข้อมูล)
+	CountsAsPresent bool   `json:"counts_as_present" gorm:"not null;default:false"`
+	SortOrder       int    `json:"sort_order" gorm:"not null;default:0"`
+	Active          bool   `json:"active" gorm:"not null;default:true"`  // ปิดแล้วเลือกใหม่ไม่ได้ (ข้อมูลเก่ายังแสดงได้)
+	System          bool   `json:"system" gorm:"not null;default:false"` // สถานะตั้งต้นของระบบ ลบ/เปลี่ยนชื่อไทยไม่ได้
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
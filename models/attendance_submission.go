@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// การส่งเช็คชื่อทั้งห้อง 1 ครั้ง (ใช้กันส่งซ้ำด้วย Idempotency-Key)
+// ส่ง key เดิม + ข้อมูลเดิม → ตอบผลเดิมโดยไม่บันทึกซ้ำ
+type AttendanceSubmission struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	UserID      uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_att_submission_key"`
+	Key         string `json:"key" gorm:"size:100;not null;uniqueIndex:idx_att_submission_key"`
+	RequestHash string `json:"-" gorm:"size:64;not null"` // sha256 ของ payload (key เดิมแต่ข้อมูลไม่ตรง → 409)
+	Grade       string `json:"grade" gorm:"size:20;not null"`
+	Room        string `json:"room" gorm:"size:10;not null"`
+	Date        string `json:"date" gorm:"size:10;not null"`
+	Count       int    `json:"count"`
+	Response    string `json:"-" gorm:"type:text"` // JSON ที่ตอบไปครั้งแรก
+
+	CreatedAt time.Time `json:"created_at"`
+}
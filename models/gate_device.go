@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// เครื่องสแกนหน้าประตู (แท็บเล็ต/เครื่องอ่านบัตร) — ยืนยันตัวด้วย API key ไม่ใช่ JWT ของคน
+// key ที่ให้เครื่อง = "<KeyPrefix>.<secret>" เก็บเฉพาะ sha256 ของทั้งก้อน
+type GateDevice struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	Name      string `json:"name" gorm:"size:80;not null"`
+	Location  string `json:"location" gorm:"size:120"`
+	Direction string `json:"direction" gorm:"size:10;not null;default:auto"` // เข้า | ออก | auto (สแกนแรกของวัน = เข้า)
+	KeyPrefix string `json:"key_prefix" gorm:"size:16;uniqueIndex;not null"`
+	KeyHash   string `json:"-" gorm:"size:64;not null"`
+	Enabled   bool   `json:"enabled" gorm:"not null;default:true"`
+
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	LastIP     string     `json:"last_ip" gorm:"size:64"`
+	CreatedBy  uint       `json:"created_by"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// LeaveAttachment ไฟล์แนบของใบลา (ใบรับรองแพทย์ ฯลฯ) — ตัวไฟล์อยู่ใน storage ตาม StorageKey
+type LeaveAttachment struct {
+	ID             uint   `json:"id" gorm:"primaryKey"`
+	LeaveRequestID uint   `json:"leave_request_id" gorm:"index;not null"`
+	FileName       string `json:"file_name" gorm:"size:255;not null"` // ชื่อไฟล์เดิมตอนอัปโหลด
+	ContentType    string `json:"content_type" gorm:"size:100;not null"`
+	Size           int64  `json:"size" gorm:"not null"`
+	StorageKey     string `json:"-" gorm:"size:255;uniqueIndex;not null"`
+
+	UploadedBy uint `json:"uploaded_by" gorm:"index;not null"` // parents.id ของผู้อัปโหลด
+
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -10,12 +10,19 @@ type LeaveRequest struct {
 	DateFrom     string     `json:"date_from" gorm:"size:10;not null"` // YYYY-MM-DD
 	DateTo       string     `json:"date_to" gorm:"size:10;not null"`   // YYYY-MM-DD
 	Attachments  int        `json:"attachments" gorm:"default:0"`      // จำนวนไฟล์แนบ
-	Status       string     `json:"status" gorm:"size:20;not null"`    // รออนุมัติ/อนุมัติ/ปฏิเสธ
+	Status       string     `json:"status" gorm:"size:20;not null"`    // รออนุมัติ/อนุมัติ/ปฏิเสธ/ยกเลิก/เพิกถอน
+	ParentID     *uint      `json:"parent_id" gorm:"index"`            // parents.id ที่ยื่น (null = ข้อมูลเก่า)
+	WithdrawnAt  *time.Time `json:"withdrawn_at"`                      // ผู้ปกครองยกเลิก (status = ยกเลิก)
 	SubmittedAt  time.Time  `json:"submitted_at" gorm:"autoCreateTime"`
 	DecidedAt    *time.Time `json:"decided_at"`
 	DecidedBy    *uint      `json:"decided_by"` // user_id ของครูที่อนุมัติ/ปฏิเสธ
 	RejectReason string     `json:"reject_reason" gorm:"type:text"`
 
+	// ผู้อนุมัติปัจจุบัน (ประวัติการส่งต่ออยู่ใน leave_routings)
+	ApproverLevel     string     `json:"approver_level" gorm:"size:20;index"` // main | secondary | admin ("" = ยังไม่ได้ส่ง)
+	ApproverTeacherID *uint      `json:"approver_teacher_id" gorm:"index"`    // teachers.id (null = admin)
+	RoutedAt          *time.Time `json:"routed_at"`                           // เริ่มนับวันส่งต่อจากเวลานี้
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
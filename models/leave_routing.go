@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LeaveRouting ประวัติการส่งใบลาให้ผู้อนุมัติแต่ละระดับ (ครูประจำชั้นหลัก → รอง → admin)
+type LeaveRouting struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	LeaveRequestID uint      `json:"leave_request_id" gorm:"index;not null"`
+	Level          string    `json:"level" gorm:"size:20;not null"`  // main | secondary | admin
+	TeacherID      *uint     `json:"teacher_id"`                     // teachers.id (null = admin)
+	Reason         string    `json:"reason" gorm:"size:30;not null"` // submitted | escalated | no_teacher
+	RoutedAt       time.Time `json:"routed_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// LeaveTransition ประวัติการเปลี่ยนสถานะใบลา (ยื่น/แก้ไข/อนุมัติ/ปฏิเสธ/ยกเลิก/เพิกถอน)
+type LeaveTransition struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	LeaveRequestID uint      `json:"leave_request_id" gorm:"index;not null"`
+	Action         string    `json:"action" gorm:"size:20;not null"` // submit | amend | approve | reject | cancel | revoke
+	FromStatus     string    `json:"from_status" gorm:"size:20"`     // "" = ยื่นใหม่
+	ToStatus       string    `json:"to_status" gorm:"size:20;not null"`
+	ActorUserID    *uint     `json:"actor_user_id"`   // users.id (ครู/admin)
+	ActorParentID  *uint     `json:"actor_parent_id"` // parents.id
+	Reason         string    `json:"reason" gorm:"type:text"`
+	Changes        string    `json:"changes" gorm:"type:text"` // JSON ค่าเดิม→ใหม่ (amend)
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// LoginAttempt บันทึกการ login ที่ล้มเหลวทุกครั้ง (ไว้ตรวจสอบย้อนหลัง)
+type LoginAttempt struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Username  string    `json:"username" gorm:"size:120;index"` // username ของ staff หรืออีเมล/เบอร์ของผู้ปกครอง
+	IP        string    `json:"ip" gorm:"size:64;index"`
+	UserAgent string    `json:"user_agent" gorm:"size:255"`
+	Reason    string    `json:"reason" gorm:"size:40"` // INVALID_CREDENTIALS | ACCOUNT_LOCKED | ...
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// LoginThrottle ตัวนับการ login ผิดต่อ username หรือต่อ IP
+type LoginThrottle struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Kind        string     `json:"kind" gorm:"size:10;not null;uniqueIndex:idx_login_throttle_key"` // username | parent | ip | reset | reset_ip
+	Value       string     `json:"value" gorm:"size:120;not null;uniqueIndex:idx_login_throttle_key"`
+	Failures    int        `json:"failures" gorm:"not null;default:0"` // จำนวนครั้งที่ผิดติดกันในช่วงเวลา
+	LastFailure *time.Time `json:"last_failure"`
+	LockedUntil *time.Time `json:"locked_until"` // ล็อกชั่วคราวถึงเวลานี้
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ParentStudent ผูกผู้ปกครองกับนักเรียน (ต้องให้แอดมิน/ครูประจำชั้นอนุมัติก่อนถึงจะเห็นข้อมูลลูก)
+type ParentStudent struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	ParentID     uint       `json:"parent_id" gorm:"not null;uniqueIndex:idx_parent_student"`
+	StudentID    uint       `json:"student_id" gorm:"not null;uniqueIndex:idx_parent_student;index"` // students.id
+	Relation     string     `json:"relation" gorm:"size:40"`                                         // บิดา/มารดา/ผู้ปกครอง
+	Status       string     `json:"status" gorm:"size:20;not null;index"`                            // รออนุมัติ/อนุมัติ/ปฏิเสธ/เพิกถอน
+	DecidedAt    *time.Time `json:"decided_at"`
+	DecidedBy    *uint      `json:"decided_by"` // user_id ของแอดมิน/ครูที่อนุมัติ/ปฏิเสธ
+	RejectReason string     `json:"reject_reason" gorm:"type:text"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
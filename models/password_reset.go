@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// PasswordResetToken token รีเซ็ตรหัสผ่านที่ส่งทางอีเมล (เก็บเฉพาะ hash, ใช้ได้ครั้งเดียว)
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index"`   // เจ้าของฝั่ง users (0 ถ้าเป็นผู้ปกครอง)
+	ParentID  uint       `json:"parent_id" gorm:"index"` // เจ้าของฝั่ง parents (0 ถ้าเป็น staff)
+	TokenHash string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"` // ใช้แล้ว หรือถูกแทนที่ด้วย token ใหม่
+	IP        string     `json:"ip" gorm:"size:64"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
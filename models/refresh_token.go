@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// RefreshToken เก็บ refresh token แบบ hash (ไม่เก็บค่า token จริงลง DB)
+// token ที่หมุน (rotate) ต่อกันมาจากการ login ครั้งเดียวกันจะใช้ FamilyID เดียวกัน = 1 session
+type RefreshToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"index"`                    // เจ้าของฝั่ง users (0 ถ้าเป็นผู้ปกครอง)
+	ParentID   uint       `json:"parent_id" gorm:"index"`                  // เจ้าของฝั่ง parents (0 ถ้าเป็น staff)
+	FamilyID   string     `json:"family_id" gorm:"size:64;index;not null"` // session id (ใส่ใน access token เป็น "sid")
+	TokenHash  string     `json:"-" gorm:"size:64;uniqueIndex;not null"`   // sha256 (hex) ของ refresh token
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt  *time.Time `json:"revoked_at"`  // ถูกใช้หมุนไปแล้ว/ออกจากระบบ
+	ReplacedBy *uint      `json:"replaced_by"` // id ของ token ตัวใหม่หลังหมุน
+	UserAgent  string     `json:"user_agent" gorm:"size:255"`
+	IP         string     `json:"ip" gorm:"size:64"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
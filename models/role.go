@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Role = ชุดสิทธิ์ที่ตั้งชื่อไว้ (users.role อ้างถึง Role.Name)
+type Role struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	Name        string           `json:"name" gorm:"size:20;uniqueIndex;not null"` // ตรงกับ users.role เช่น admin, teacher, registrar
+	DisplayName string           `json:"display_name" gorm:"size:100"`             // ชื่อที่แสดง เช่น "งานทะเบียน"
+	Description string           `json:"description" gorm:"size:255"`
+	System      bool             `json:"system" gorm:"not null;default:false"` // role ตั้งต้นของระบบ ลบไม่ได้
+	Permissions []RolePermission `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RolePermission สิทธิ์หนึ่งข้อของ role (ค่าตาม catalogue ใน handlers/permissions.go)
+type RolePermission struct {
+	ID         uint   `gorm:"primaryKey"`
+	RoleID     uint   `gorm:"not null;uniqueIndex:idx_role_permission"`
+	Permission string `gorm:"size:64;not null;uniqueIndex:idx_role_permission"`
+}
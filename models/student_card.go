@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// บัตรนักเรียน (RFID/NFC) ที่ใช้สแกนหน้าประตู — 1 คนมีได้หลายใบ (บัตรหาย/ออกใหม่)
+type StudentCard struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	StudentID uint   `json:"student_id" gorm:"index;not null"`
+	CardUID   string `json:"card_uid" gorm:"size:64;uniqueIndex;not null"` // UID ที่เครื่องอ่านได้ (ตัวพิมพ์ใหญ่ ไม่มี : หรือช่องว่าง)
+	Active    bool   `json:"active" gorm:"not null;default:true"`
+	CreatedBy uint   `json:"created_by"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
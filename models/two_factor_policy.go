@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// TwoFactorPolicy = role นี้ต้องเปิด 2FA ก่อนถึงจะใช้งานระบบได้
+type TwoFactorPolicy struct {
+	ID        uint   `gorm:"primaryKey"`
+	Role      string `gorm:"size:20;uniqueIndex;not null"` // roles.name
+	Required  bool   `gorm:"not null;default:false"`
+	UpdatedBy *uint  // users.id ของแอดมินที่ตั้งค่าล่าสุด
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
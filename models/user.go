@@ -21,6 +21,13 @@ type User struct {
 	LastLogin          *time.Time
 	LastPasswordChange *time.Time
 
+	// ===== 2FA (TOTP) =====
+	TOTPSecret        string     `gorm:"column:totp_secret;size:64"`                 // base32, ตั้งตอน setup (ยังไม่มีผลจนกว่าจะ enable)
+	TOTPEnabled       bool       `gorm:"column:totp_enabled;not null;default:false"` // login ต้องใส่รหัส 6 หลักด้วย
+	TOTPEnabledAt     *time.Time `gorm:"column:totp_enabled_at"`
+	TOTPLastStep      int64      `gorm:"column:totp_last_step;not null;default:0"` // time step ล่าสุดที่ใช้แล้ว (กันใช้รหัสเดิมซ้ำ)
+	TOTPRecoveryCodes string     `gorm:"column:totp_recovery_codes;type:text"`     // sha256 ของ recovery code ที่ยังไม่ถูกใช้ คั่นด้วย ,
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -0,0 +1,33 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// WriteCSV เขียน UTF-8 พร้อม BOM (ให้ Excel เปิดภาษาไทยได้ถูก)
+func (r *Register) WriteCSV(w io.Writer) error {
+	if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{r.Title()})
+	_ = cw.Write([]string{r.Subtitle()})
+	_ = cw.Write(r.header())
+	for _, row := range r.Rows {
+		_ = cw.Write(r.record(row))
+	}
+	// แถวหมายเหตุวันหยุด
+	notes := []string{"", "", "วันหยุด"}
+	for _, d := range r.Days {
+		if d.SchoolDay {
+			notes = append(notes, "")
+		} else {
+			notes = append(notes, "-")
+		}
+	}
+	_ = cw.Write(notes)
+	_ = cw.Write([]string{Legend()})
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,98 @@
+package report
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ErrNoFont ยังไม่ได้ตั้งฟอนต์ภาษาไทย (REPORT_FONT_PATH) — ฟอนต์มาตรฐานของ PDF ไม่มีอักษรไทย
+var ErrNoFont = errors.New("report: thai font not configured")
+
+// WritePDF A4 แนวนอน พร้อมพิมพ์ (หัวตารางซ้ำทุกหน้า) — fontPath = ไฟล์ .ttf ที่มีอักษรไทย เช่น THSarabunNew.ttf
+func (r *Register) WritePDF(w io.Writer, fontPath string) error {
+	if fontPath == "" {
+		return ErrNoFont
+	}
+	// gofpdf ต่อชื่อไฟล์ฟอนต์เข้ากับ font dir เสมอ → แยก dir/ชื่อไฟล์
+	pdf := gofpdf.New("L", "mm", "A4", filepath.Dir(fontPath))
+	pdf.SetMargins(8, 8, 8)
+	pdf.SetAutoPageBreak(false, 8)
+	pdf.AddUTF8Font("th", "", filepath.Base(fontPath))
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+
+	const (
+		rowH   = 5.5
+		noW    = 8.0
+		codeW  = 16.0
+		nameW  = 46.0
+		totalW = 8.0
+	)
+	pageW, pageH := pdf.GetPageSize()
+	left, _, right, bottom := pdf.GetMargins()
+	dayW := 6.0
+	if n := len(r.Days); n > 0 {
+		dayW = (pageW - left - right - noW - codeW - nameW - 4*totalW) / float64(n)
+	}
+
+	header := func() {
+		pdf.AddPage()
+		pdf.SetFont("th", "", 16)
+		pdf.CellFormat(0, 7, r.Title(), "", 1, "C", false, 0, "")
+		pdf.SetFont("th", "", 12)
+		pdf.CellFormat(0, 6, r.Subtitle(), "", 1, "C", false, 0, "")
+		pdf.Ln(1)
+
+		pdf.SetFont("th", "", 11)
+		pdf.SetFillColor(242, 242, 242)
+		pdf.CellFormat(noW, rowH, "ที่", "1", 0, "C", true, 0, "")
+		pdf.CellFormat(codeW, rowH, "รหัส", "1", 0, "C", true, 0, "")
+		pdf.CellFormat(nameW, rowH, "ชื่อ-สกุล", "1", 0, "C", true, 0, "")
+		for _, d := range r.Days {
+			if d.SchoolDay {
+				pdf.SetFillColor(242, 242, 242)
+			} else {
+				pdf.SetFillColor(200, 200, 200)
+			}
+			pdf.CellFormat(dayW, rowH, strconv.Itoa(d.Day), "1", 0, "C", true, 0, "")
+		}
+		pdf.SetFillColor(242, 242, 242)
+		for _, h := range []string{"มา", "สาย", "ลา", "ขาด"} {
+			pdf.CellFormat(totalW, rowH, h, "1", 0, "C", true, 0, "")
+		}
+		pdf.Ln(rowH)
+	}
+
+	header()
+	pdf.SetFillColor(200, 200, 200)
+	for _, row := range r.Rows {
+		if _, y := pdf.GetXY(); y+rowH > pageH-bottom-rowH {
+			header()
+			pdf.SetFillColor(200, 200, 200)
+		}
+		pdf.CellFormat(noW, rowH, strconv.Itoa(row.No), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(codeW, rowH, row.Code, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(nameW, rowH, row.Name, "1", 0, "L", false, 0, "")
+		for i, d := range r.Days {
+			mark := ""
+			if i < len(row.Marks) {
+				mark = row.Marks[i]
+			}
+			pdf.CellFormat(dayW, rowH, mark, "1", 0, "C", !d.SchoolDay, 0, "")
+		}
+		for _, v := range []int{row.Totals.Present, row.Totals.Late, row.Totals.Leave, row.Totals.Absent} {
+			pdf.CellFormat(totalW, rowH, strconv.Itoa(v), "1", 0, "C", false, 0, "")
+		}
+		pdf.Ln(rowH)
+	}
+	pdf.Ln(2)
+	pdf.SetFont("th", "", 11)
+	pdf.CellFormat(0, rowH, Legend(), "", 1, "L", false, 0, "")
+
+	return pdf.Output(w)
+}
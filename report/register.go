@@ -0,0 +1,94 @@
+// Package report สร้างเอกสารส่งออก (แบบบันทึกเวลาเรียนรายเดือน) เป็น CSV / XLSX / PDF
+package report
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// เครื่องหมายในแบบบันทึกเวลาเรียน
+const (
+	MarkPresent = "/"
+	MarkLate    = "ส"
+	MarkLeave   = "ล"
+	MarkAbsent  = "ข"
+)
+
+var thaiMonths = [...]string{"", "มกราคม", "กุมภาพันธ์", "มีนาคม", "เมษายน", "พฤษภาคม", "มิถุนายน",
+	"กรกฎาคม", "สิงหาคม", "กันยายน", "ตุลาคม", "พฤศจิกายน", "ธันวาคม"}
+
+// ThaiMonth "2025-06" → "มิถุนายน 2568"
+func ThaiMonth(year, month int) string {
+	if month < 1 || month > 12 {
+		return ""
+	}
+	return thaiMonths[month] + " " + strconv.Itoa(year+543)
+}
+
+// Day = 1 คอลัมน์ (ทุกวันของเดือน; วันที่ไม่ใช่วันเรียนจะถูกแรเงา)
+type Day struct {
+	Date      string // YYYY-MM-DD
+	Day       int    // 1..31
+	SchoolDay bool
+	Note      string // ชื่อวันหยุด / "เสาร์-อาทิตย์" / "นอกภาคเรียน"
+}
+
+type Totals struct {
+	Present int
+	Late    int
+	Leave   int
+	Absent  int
+}
+
+// Row = นักเรียน 1 คน; Marks เรียงตาม Register.Days ("" = ไม่มีข้อมูล/ไม่ใช่วันเรียน)
+type Row struct {
+	No     int
+	Code   string
+	Name   string
+	Marks  []string
+	Totals Totals
+}
+
+type Register struct {
+	School     string
+	Grade      string
+	Room       string
+	MonthLabel string // "มิถุนายน 2568"
+	SchoolDays int
+	Days       []Day
+	Rows       []Row
+}
+
+func (r *Register) Title() string {
+	return "แบบบันทึกเวลาเรียน"
+}
+
+func (r *Register) Subtitle() string {
+	s := fmt.Sprintf("ชั้น %s ห้อง %s  ประจำเดือน %s  (วันเรียน %d วัน)", r.Grade, r.Room, r.MonthLabel, r.SchoolDays)
+	if r.School != "" {
+		s = r.School + "  " + s
+	}
+	return s
+}
+
+// Legend คำอธิบายเครื่องหมาย
+func Legend() string {
+	return MarkPresent + " = มา   " + MarkLate + " = มาสาย   " + MarkLeave + " = ลา   " + MarkAbsent + " = ขาด   ช่องแรเงา = วันหยุด"
+}
+
+// หัวตาราง: ที่, รหัส, ชื่อ-สกุล, 1..N, มา, สาย, ลา, ขาด
+func (r *Register) header() []string {
+	h := []string{"ที่", "รหัส", "ชื่อ-สกุล"}
+	for _, d := range r.Days {
+		h = append(h, strconv.Itoa(d.Day))
+	}
+	return append(h, "มา", "สาย", "ลา", "ขาด")
+}
+
+func (r *Register) record(row Row) []string {
+	rec := []string{strconv.Itoa(row.No), row.Code, row.Name}
+	rec = append(rec, row.Marks...)
+	return append(rec,
+		strconv.Itoa(row.Totals.Present), strconv.Itoa(row.Totals.Late),
+		strconv.Itoa(row.Totals.Leave), strconv.Itoa(row.Totals.Absent))
+}
@@ -0,0 +1,79 @@
+package report
+
+import (
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// WriteXLSX 1 sheet: หัวเรื่อง 2 แถว, หัวตาราง, นักเรียน, คำอธิบาย — คอลัมน์วันหยุดแรเงาเทา
+func (r *Register) WriteXLSX(w io.Writer) error {
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := "Register"
+	f.SetSheetName("Sheet1", sheet)
+
+	header := r.header()
+	lastCol, _ := excelize.ColumnNumberToName(len(header))
+	cell := func(col, row int) string {
+		name, _ := excelize.CoordinatesToCellName(col, row)
+		return name
+	}
+
+	_ = f.SetCellValue(sheet, "A1", r.Title())
+	_ = f.SetCellValue(sheet, "A2", r.Subtitle())
+	_ = f.MergeCell(sheet, "A1", lastCol+"1")
+	_ = f.MergeCell(sheet, "A2", lastCol+"2")
+	titleStyle, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}})
+	_ = f.SetCellStyle(sheet, "A1", "A1", titleStyle)
+
+	border := []excelize.Border{
+		{Type: "left", Color: "000000", Style: 1}, {Type: "right", Color: "000000", Style: 1},
+		{Type: "top", Color: "000000", Style: 1}, {Type: "bottom", Color: "000000", Style: 1},
+	}
+	center := &excelize.Alignment{Horizontal: "center", Vertical: "center"}
+	headStyle, _ := f.NewStyle(&excelize.Style{Border: border, Alignment: center, Font: &excelize.Font{Bold: true}})
+	bodyStyle, _ := f.NewStyle(&excelize.Style{Border: border, Alignment: center})
+	nameStyle, _ := f.NewStyle(&excelize.Style{Border: border})
+	shadeStyle, _ := f.NewStyle(&excelize.Style{
+		Border: border, Alignment: center,
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"D9D9D9"}},
+	})
+
+	const headRow = 3
+	for i, h := range header {
+		_ = f.SetCellValue(sheet, cell(i+1, headRow), h)
+	}
+	_ = f.SetCellStyle(sheet, cell(1, headRow), cell(len(header), headRow), headStyle)
+
+	for i, row := range r.Rows {
+		y := headRow + 1 + i
+		for j, v := range r.record(row) {
+			_ = f.SetCellValue(sheet, cell(j+1, y), v)
+		}
+		_ = f.SetCellStyle(sheet, cell(1, y), cell(len(header), y), bodyStyle)
+		_ = f.SetCellStyle(sheet, cell(3, y), cell(3, y), nameStyle)
+	}
+	lastRow := headRow + len(r.Rows)
+	for i, d := range r.Days {
+		if !d.SchoolDay {
+			_ = f.SetCellStyle(sheet, cell(4+i, headRow), cell(4+i, lastRow), shadeStyle)
+		}
+	}
+	_ = f.SetCellValue(sheet, cell(1, lastRow+2), Legend())
+
+	_ = f.SetColWidth(sheet, "A", "A", 5)
+	_ = f.SetColWidth(sheet, "B", "B", 10)
+	_ = f.SetColWidth(sheet, "C", "C", 28)
+	if len(r.Days) > 0 {
+		first, _ := excelize.ColumnNumberToName(4)
+		last, _ := excelize.ColumnNumberToName(3 + len(r.Days))
+		_ = f.SetColWidth(sheet, first, last, 3.5)
+	}
+	_ = f.SetPanes(sheet, &excelize.Panes{Freeze: true, XSplit: 3, YSplit: headRow, TopLeftCell: "D4", ActivePane: "bottomRight"})
+	_ = f.SetPageLayout(sheet, &excelize.PageLayoutOptions{Orientation: strPtr("landscape")})
+
+	return f.Write(w)
+}
+
+func strPtr(s string) *string { return &s }
@@ -13,73 +13,204 @@ func RegisterRoutes(e *echo.Echo) {
 	// auth
 	auth := handlers.NewAuthHandler()
 	e.POST("/auth/login", auth.StaffLogin)
+	e.POST("/auth/refresh", auth.Refresh)
+	e.POST("/auth/logout", auth.Logout, auth.RequireAuth)
 	e.GET("/auth/me", auth.Me, auth.RequireAuth)
+	e.GET("/.well-known/jwks.json", auth.JWKS)
+	e.POST("/auth/login/2fa", auth.LoginTwoFactor)
+
+	// ลืมรหัสผ่าน (staff + ผู้ปกครอง) — ส่งลิงก์ทางอีเมล
+	e.POST("/auth/forgot", auth.ForgotPassword)
+	e.POST("/auth/reset", auth.ResetPasswordWithToken)
+
+	// 2FA (TOTP) ของบัญชีตัวเอง — setup/enable รับ token บังคับตั้ง 2FA ได้
+	e.GET("/auth/2fa", auth.TwoFactorStatus, auth.RequireTwoFactorEnrollAuth)
+	e.POST("/auth/2fa/setup", auth.TwoFactorSetup, auth.RequireTwoFactorEnrollAuth)
+	e.POST("/auth/2fa/enable", auth.TwoFactorEnable, auth.RequireTwoFactorEnrollAuth)
+	e.POST("/auth/2fa/disable", auth.TwoFactorDisable, auth.RequireAuth)
+	e.POST("/auth/2fa/recovery-codes", auth.TwoFactorRecoveryCodes, auth.RequireAuth)
+
+	// auth (ผู้ปกครอง)
+	e.POST("/auth/parent/register", auth.ParentRegister)
+	e.POST("/auth/parent/login", auth.ParentLogin)
+
+	// เปลี่ยนรหัสผ่าน (รับ token จำกัดสิทธิ์ของบัญชีที่ถูกบังคับเปลี่ยนรหัสได้)
+	profile := handlers.NewTeacherProfileHandler()
+	e.POST("/auth/password", profile.ChangePassword, auth.RequirePasswordChangeAuth)
 
 	// ===== Protected root group (ต้องมี token) =====
+	// แต่ละ route ประกาศสิทธิ์ที่ต้องใช้ (role = ชุดสิทธิ์ในตาราง roles, admin ได้ทุกสิทธิ์)
 	secured := e.Group("", auth.RequireAuth)
-
-	/* ===== Admin-only endpoints ===== */
-	adminOnly := secured.Group("", auth.RequireRoles("admin"))
+	can := auth.RequirePermission
 
 	// School (อ่าน/แก้ไข)
 	school := handlers.NewSchoolHandler()
-	adminOnly.GET("/school", school.GetSchool)
-	adminOnly.POST("/school", school.CreateOrUpdate)
-	adminOnly.PUT("/school", school.CreateOrUpdate)
-	adminOnly.DELETE("/school", school.DeleteSchool)
+	secured.GET("/school", school.GetSchool, can(handlers.PermSchoolManage))
+	secured.POST("/school", school.CreateOrUpdate, can(handlers.PermSchoolManage))
+	secured.PUT("/school", school.CreateOrUpdate, can(handlers.PermSchoolManage))
+	secured.DELETE("/school", school.DeleteSchool, can(handlers.PermSchoolManage))
 
 	// Teachers / Students (รายการ)
 	teacher := handlers.NewTeacherHandler()
-	adminOnly.GET("/teachers", teacher.List)
+	secured.GET("/teachers", teacher.List, can(handlers.PermTeachersRead))
 
 	student := handlers.NewStudentHandler()
-	adminOnly.GET("/students", student.List)
+	secured.GET("/students", student.List, can(handlers.PermStudentsRead))
+	secured.GET("/students/:id", student.Get, can(handlers.PermStudentsRead))
+	secured.GET("/teacher/students-summary", handlers.NewTeacherStudentsSummaryHandler().List, can(handlers.PermAttendanceRead))
 
 	// Teacher accounts (สร้าง/จัดการบัญชีครู)
 	acc := handlers.NewTeacherAccountHandler()
-	adminOnly.GET("/teacher-accounts", acc.List)
-	adminOnly.POST("/teacher-accounts", acc.Create)
-	adminOnly.POST("/teacher-accounts/:id/reset", acc.ResetPassword)
-	adminOnly.PATCH("/teacher-accounts/:id", acc.UpdateFlags)
+	secured.GET("/teacher-accounts", acc.List, can(handlers.PermAccountsManage))
+	secured.POST("/teacher-accounts", acc.Create, can(handlers.PermAccountsManage))
+	secured.POST("/teacher-accounts/:id/reset", acc.ResetPassword, can(handlers.PermAccountsManage))
+	secured.PATCH("/teacher-accounts/:id", acc.UpdateFlags, can(handlers.PermAccountsManage))
+	secured.POST("/teacher-accounts/:id/logout", acc.RevokeSessions, can(handlers.PermAccountsManage))
+	secured.POST("/teacher-accounts/:id/2fa/reset", acc.ResetTwoFactor, can(handlers.PermAccountsManage))
+
+	// Roles / สิทธิ์ / กำหนด role ให้ผู้ใช้
+	roles := handlers.NewRoleHandler()
+	secured.GET("/permissions", roles.Permissions, can(handlers.PermRolesManage))
+	secured.GET("/roles", roles.List, can(handlers.PermRolesManage))
+	secured.POST("/roles", roles.Create, can(handlers.PermRolesManage))
+	secured.PUT("/roles/:id", roles.Update, can(handlers.PermRolesManage))
+	secured.DELETE("/roles/:id", roles.Delete, can(handlers.PermRolesManage))
+	secured.GET("/users", roles.ListUsers, can(handlers.PermRolesManage))
+	secured.PUT("/users/:id/role", roles.AssignRole, can(handlers.PermRolesManage))
+
+	// กุญแจเซ็น JWT (หมุนกุญแจ: วางไฟล์ใหม่แล้ว reload)
+	secured.GET("/auth/keys", auth.ListKeys, can(handlers.PermSecurityManage))
+	secured.POST("/auth/keys/reload", auth.ReloadKeys, can(handlers.PermSecurityManage))
+
+	// บังคับ 2FA ตาม role
+	secured.GET("/auth/2fa/policy", auth.GetTwoFactorPolicy, can(handlers.PermSecurityManage))
+	secured.PUT("/auth/2fa/policy", auth.SetTwoFactorPolicy, can(handlers.PermSecurityManage))
+
+	// บัญชีที่ถูกล็อกจากการ login ผิดซ้ำ ๆ
+	secured.GET("/auth/lockouts", auth.ListLockouts, can(handlers.PermSecurityManage))
+	secured.DELETE("/auth/lockouts/:id", auth.Unlock, can(handlers.PermSecurityManage))
+	secured.GET("/auth/login-attempts", auth.ListLoginAttempts, can(handlers.PermSecurityManage))
 
 	// ย้ายนักเรียน (move)
 	mv := handlers.NewStudentMoveHandler()
-	adminOnly.GET("/moves", mv.List)
-	adminOnly.POST("/moves", mv.Create)
-	adminOnly.PUT("/moves/:id", mv.Update)
-	adminOnly.DELETE("/moves/:id", mv.Delete)
+	secured.GET("/moves", mv.List, can(handlers.PermStudentsRead))
+	secured.POST("/moves", mv.Create, can(handlers.PermStudentsManage))
+	secured.PUT("/moves/:id", mv.Update, can(handlers.PermStudentsManage))
+	secured.DELETE("/moves/:id", mv.Delete, can(handlers.PermStudentsManage))
 
-	// Calendar (สร้าง/แก้/ลบ)
+	// Calendar
 	cal := handlers.NewCalendarHandler()
-	adminOnly.POST("/calendar/:kind", cal.Create)
-	adminOnly.PUT("/calendar/:kind/:id", cal.Update)
-	adminOnly.DELETE("/calendar/:kind/:id", cal.Delete)
-
-	/* ===== Admin + Teacher (อ่านได้) ===== */
-	adminOrTeacher := secured.Group("", auth.RequireRoles("admin", "teacher"))
+	secured.GET("/calendar/:kind", cal.List, can(handlers.PermCalendarRead))
+	secured.POST("/calendar/:kind", cal.Create, can(handlers.PermCalendarManage))
+	secured.PUT("/calendar/:kind/:id", cal.Update, can(handlers.PermCalendarManage))
+	secured.DELETE("/calendar/:kind/:id", cal.Delete, can(handlers.PermCalendarManage))
 
-	// homerooms, calendar (read)
+	// homerooms (read)
 	homeroom := handlers.NewHomeroomHandler()
-	adminOrTeacher.GET("/homerooms", homeroom.List)
-
-	adminOrTeacher.GET("/calendar/:kind", cal.List)
+	secured.GET("/homerooms", homeroom.List, can(handlers.PermHomeroomsRead))
+	secured.GET("/homerooms/:id", homeroom.Get, can(handlers.PermHomeroomsRead))
 
 	// leave requests (อ่านจากแอพผู้ปกครอง)
 	leave := handlers.NewLeaveRequestHandler()
-	adminOrTeacher.GET("/leave-requests", leave.List)
-	adminOrTeacher.GET("/leave-requests/:id", leave.Get)
+	secured.GET("/leave-requests", leave.List, can(handlers.PermLeaveRead))
+	secured.GET("/leave-requests/pending-count", leave.PendingCount, can(handlers.PermLeaveRead))
+	secured.GET("/leave-requests/my-approvals", leave.MyApprovals, can(handlers.PermLeaveApprove))
+	secured.GET("/leave-requests/:id", leave.Get, can(handlers.PermLeaveRead))
+	secured.GET("/leave-requests/:id/routing", leave.Routing, can(handlers.PermLeaveRead))
+	secured.POST("/leave-requests/:id/approve", leave.Approve, can(handlers.PermLeaveApprove))
+	secured.POST("/leave-requests/:id/reject", leave.Reject, can(handlers.PermLeaveApprove))
+	secured.POST("/leave-requests/:id/revoke", leave.Revoke, can(handlers.PermLeaveAdmin))
+
+	// ไฟล์แนบใบลา (ครูดู/ดาวน์โหลดได้เฉพาะนักเรียนในขอบเขต)
+	leaveFiles := handlers.NewLeaveAttachmentHandler()
+	secured.GET("/leave-requests/:id/attachments", leaveFiles.List, can(handlers.PermLeaveRead))
+	secured.GET("/leave-requests/:id/attachments/:aid", leaveFiles.Download, can(handlers.PermLeaveRead))
+
+	// การเข้าเรียน (ครูเห็น/เช็คชื่อได้เฉพาะห้องที่ประจำชั้น)
+	att := handlers.NewAttendanceHandler()
+	secured.GET("/attendance", att.List, can(handlers.PermAttendanceRead))
+	secured.POST("/attendance", att.Mark, can(handlers.PermAttendanceMark))
+	secured.POST("/attendance/roll-call", att.RollCall, can(handlers.PermAttendanceMark))
+
+	// รายการสถานะการเข้าเรียน (admin เพิ่ม/แก้ได้)
+	statuses := handlers.NewAttendanceStatusHandler()
+	secured.GET("/attendance-statuses", statuses.List, can(handlers.PermAttendanceRead))
+	secured.POST("/attendance-statuses", statuses.Create, can(handlers.PermAttendanceAdmin))
+	secured.GET("/attendance-statuses/unmapped", statuses.Unmapped, can(handlers.PermAttendanceAdmin))
+	secured.POST("/attendance-statuses/remap", statuses.Remap, can(handlers.PermAttendanceAdmin))
+	secured.PUT("/attendance-statuses/:id", statuses.Update, can(handlers.PermAttendanceAdmin))
+	secured.DELETE("/attendance-statuses/:id", statuses.Delete, can(handlers.PermAttendanceAdmin))
+
+	// สถิติรายคน / กลุ่มเสี่ยง (ต่อภาคเรียน/เดือน/ช่วงวันที่)
+	stats := handlers.NewAttendanceStatsHandler()
+	secured.GET("/attendance/stats", stats.Stats, can(handlers.PermAttendanceRead))
+	secured.GET("/attendance/stats/at-risk", stats.AtRisk, can(handlers.PermAttendanceRead))
+
+	// แบบบันทึกเวลาเรียนรายเดือน (CSV / XLSX / PDF)
+	register := handlers.NewAttendanceRegisterHandler()
+	secured.GET("/attendance/register", register.Export, can(handlers.PermAttendanceRead))
+
+	// แก้ไข/ยกเลิก (ข้อมูลเก่าเกินกำหนดต้องรอ admin อนุมัติ)
+	secured.PUT("/attendance/:id", att.Update, can(handlers.PermAttendanceMark))
+	secured.POST("/attendance/:id/void", att.Void, can(handlers.PermAttendanceMark))
+	secured.GET("/attendance/:id/history", att.History, can(handlers.PermAttendanceRead))
+	secured.GET("/attendance/corrections", att.ListCorrections, can(handlers.PermAttendanceAdmin))
+	secured.POST("/attendance/corrections/:id/approve", att.ApproveCorrection, can(handlers.PermAttendanceAdmin))
+	secured.POST("/attendance/corrections/:id/reject", att.RejectCorrection, can(handlers.PermAttendanceAdmin))
+
+	// เติม "ขาด" อัตโนมัติ (รันเองหลังเลิกเรียน / สั่งรันย้อนหลัง)
+	absences := handlers.NewAbsenceJobHandler()
+	secured.POST("/attendance/absences/run", absences.Run, can(handlers.PermAttendanceAdmin))
+	secured.GET("/attendance/absences/runs", absences.Runs, can(handlers.PermAttendanceAdmin))
+
+	// เครื่องสแกนหน้าประตู + บัตรนักเรียน (admin) / QR ของนักเรียน
+	gate := handlers.NewGateHandler(att)
+	secured.GET("/gate-devices", gate.ListDevices, can(handlers.PermGateManage))
+	secured.POST("/gate-devices", gate.CreateDevice, can(handlers.PermGateManage))
+	secured.PUT("/gate-devices/:id", gate.UpdateDevice, can(handlers.PermGateManage))
+	secured.POST("/gate-devices/:id/rotate-key", gate.RotateDeviceKey, can(handlers.PermGateManage))
+	secured.DELETE("/gate-devices/:id", gate.DeleteDevice, can(handlers.PermGateManage))
+	secured.GET("/students/:id/cards", gate.ListCards, can(handlers.PermGateManage))
+	secured.POST("/students/:id/cards", gate.AddCard, can(handlers.PermGateManage))
+	secured.DELETE("/student-cards/:id", gate.DeleteCard, can(handlers.PermGateManage))
+	secured.GET("/students/:id/qr", gate.StudentQR, can(handlers.PermAttendanceMark))
+
+	// เครื่องสแกนเรียกเอง (ยืนยันด้วย X-Device-Key ไม่ใช่ JWT)
+	e.POST("/gate/scan", gate.Scan, gate.RequireDevice)
+
+	// คำขอผูกบัญชีผู้ปกครอง-นักเรียน (classes.all เห็นทุกห้อง / นอกนั้นเฉพาะห้องที่ประจำชั้น)
+	links := handlers.NewParentLinkHandler()
+	secured.GET("/parent-links", links.List, can(handlers.PermParentLinks))
+	secured.POST("/parent-links/:id/approve", links.Approve, can(handlers.PermParentLinks))
+	secured.POST("/parent-links/:id/reject", links.Reject, can(handlers.PermParentLinks))
+	secured.POST("/parent-links/:id/revoke", links.Revoke, can(handlers.PermParentLinks))
 
 	// dashboard/summary (อ่าน)
 	dash := handlers.NewDashboardHandler()
-	adminOrTeacher.GET("/dashboard/summary", dash.Summary)
-
-	// teacher-accounts
-	e.PATCH("/teacher-accounts/:id", acc.UpdateFlags)
-
-	// leave requests
-	e.GET("/leave-requests", leave.Get)
-
-	// dashboard
-	e.GET("/dashboard/summary", dash.Summary)
-
+	secured.GET("/dashboard/summary", dash.Summary, can(handlers.PermDashboardRead))
+	secured.GET("/dashboard/daily", dash.Daily, can(handlers.PermDashboardRead))
+
+	// ข้อมูลบัญชีของคนที่ login อยู่ (staff ทุก role)
+	secured.GET("/teacher/me", handlers.TeacherMe, auth.RequireStaff)
+	secured.GET("/teacher/profile", handlers.TeacherGetProfile, auth.RequireStaff)
+	secured.PUT("/teacher/profile", handlers.TeacherUpdateProfile, auth.RequireStaff)
+
+	/* ===== Parent ===== */
+	parent := secured.Group("/parent", auth.RequireRoles("parent"))
+	parent.GET("/children", handlers.ParentChildren)
+	parent.POST("/children", links.RequestLink)
+	parent.GET("/children/:id/qr", gate.ParentChildQR)
+
+	// ใบลาที่ผู้ปกครองยื่นให้ลูก
+	parentLeave := handlers.NewParentLeaveHandler()
+	parent.POST("/children/:id/leave-requests", parentLeave.Submit)
+	parent.GET("/leave-requests", parentLeave.List)
+	parent.GET("/leave-requests/:id", parentLeave.Get)
+	parent.PUT("/leave-requests/:id", parentLeave.Amend)
+	parent.POST("/leave-requests/:id/cancel", parentLeave.Cancel)
+	parent.POST("/leave-requests/:id/withdraw", parentLeave.Cancel) // ชื่อเดิม
+	parent.POST("/leave-requests/:id/attachments", leaveFiles.Upload)
+	parent.GET("/leave-requests/:id/attachments", leaveFiles.List)
+	parent.GET("/leave-requests/:id/attachments/:aid", leaveFiles.Download)
+	parent.DELETE("/leave-requests/:id/attachments/:aid", leaveFiles.Delete)
 }
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiskRejectsTraversal(t *testing.T) {
+	root := t.TempDir()
+	d := &DiskStorage{Dir: filepath.Join(root, "uploads")}
+	ctx := context.Background()
+
+	for _, key := range []string{
+		"../secret.txt",
+		"leave/../../secret.txt",
+		"leave/12/../../../secret.txt",
+		"..",
+		"/",
+		"",
+	} {
+		if err := d.Put(ctx, key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("Put(%q): expected error", key)
+		}
+		if _, err := d.Get(ctx, key); err == nil {
+			t.Errorf("Get(%q): expected error", key)
+		}
+		if err := d.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q): expected error", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "secret.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file written outside storage dir: %v", err)
+	}
+}
+
+func TestDiskPutGetDelete(t *testing.T) {
+	d := &DiskStorage{Dir: t.TempDir()}
+	ctx := context.Background()
+	key := "leave/12/a.pdf"
+
+	if err := d.Put(ctx, key, strings.NewReader("hello"), 5, "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	rc, err := d.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(got) != "hello" {
+		t.Errorf("Get = %q", got)
+	}
+	if err := d.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := d.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after delete: err = %v, want ErrNotFound", err)
+	}
+}
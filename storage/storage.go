@@ -0,0 +1,46 @@
+// Package storage เก็บไฟล์ที่ผู้ใช้อัปโหลด (ไฟล์แนบใบลา ฯลฯ)
+// เลือกที่เก็บด้วย STORAGE_DRIVER: local | s3 (ค่าเริ่มต้น local สำหรับ dev)
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNotFound ไม่มีไฟล์ตาม key
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage ที่เก็บไฟล์ (ต่อกับผู้ให้บริการอื่นได้โดย implement interface นี้)
+// key เป็น path แบบ "leave/12/abc.pdf" (ใช้ "/" เสมอ)
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+func getenv(k, def string) string {
+	if v := strings.TrimSpace(os.Getenv(k)); v != "" {
+		return v
+	}
+	return def
+}
+
+// FromEnv สร้าง Storage ตาม STORAGE_DRIVER
+func FromEnv() Storage {
+	switch strings.ToLower(getenv("STORAGE_DRIVER", "local")) {
+	case "s3":
+		return &S3Storage{
+			Endpoint:  getenv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:    getenv("S3_REGION", "us-east-1"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PathStyle: strings.ToLower(getenv("S3_PATH_STYLE", "true")) != "false",
+		}
+	default:
+		return &DiskStorage{Dir: getenv("STORAGE_LOCAL_DIR", "uploads")}
+	}
+}